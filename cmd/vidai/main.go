@@ -5,6 +5,7 @@ import (
 	"log"
 	"os"
 	"os/signal"
+	"syscall"
 
 	"github.com/igolaizola/vidai/pkg/cli"
 )
@@ -16,9 +17,23 @@ var date = ""
 
 func main() {
 	// Create signal based context
-	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	// The first signal cancels the context so that commands can cancel
+	// pending tasks and remove temporary files and uploaded assets.
+	// A second signal forces the exit without waiting for the cleanup.
+	sigs := make(chan os.Signal, 2)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sigs
+		log.Println("vidai: shutting down, press ctrl+c again to force exit")
+		cancel()
+		<-sigs
+		log.Println("vidai: forced exit")
+		os.Exit(1)
+	}()
+
 	// Launch command
 	cmd := cli.NewCommand(version, commit, date)
 	if err := cmd.ParseAndRun(ctx, os.Args[1:]); err != nil {
@@ -9,6 +9,8 @@ require (
 	github.com/bogdanfinn/tls-client v1.7.5
 	github.com/golang-jwt/jwt v3.2.2+incompatible
 	github.com/peterbourgon/ff/v3 v3.3.0
+	golang.org/x/image v0.23.0
+	gopkg.in/yaml.v2 v2.4.0
 )
 
 require (
@@ -22,5 +24,4 @@ require (
 	golang.org/x/net v0.28.0 // indirect
 	golang.org/x/sys v0.28.0 // indirect
 	golang.org/x/text v0.21.0 // indirect
-	gopkg.in/yaml.v2 v2.4.0 // indirect
 )
@@ -0,0 +1,166 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Entry is an uploaded dataset stored in the cache.
+type Entry struct {
+	URL       string    `json:"url"`
+	DatasetID string    `json:"datasetId"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Cache maps the MD5 of uploaded files to their runway datasets.
+// Entries are persisted to a JSON file so they can be reused across runs.
+type Cache struct {
+	path    string
+	ttl     time.Duration
+	lck     sync.Mutex
+	entries map[string]*Entry
+}
+
+// DefaultPath returns the default location of the cache file.
+func DefaultPath() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("cache: couldn't get user cache dir: %w", err)
+	}
+	return filepath.Join(dir, "vidai", "uploads.json"), nil
+}
+
+// Open loads the cache from the given path. If the path is empty the default
+// path is used. Entries older than ttl are ignored, a ttl of 0 means entries
+// never expire.
+func Open(path string, ttl time.Duration) (*Cache, error) {
+	if path == "" {
+		var err error
+		path, err = DefaultPath()
+		if err != nil {
+			return nil, err
+		}
+	}
+	c := &Cache{
+		path: path,
+		ttl:  ttl,
+	}
+	entries, err := c.load()
+	if err != nil {
+		return nil, err
+	}
+	c.entries = entries
+	return c, nil
+}
+
+// Get returns the url and dataset ID of a cached upload.
+func (c *Cache) Get(key string) (string, string, bool) {
+	c.lck.Lock()
+	defer c.lck.Unlock()
+	e, ok := c.entries[key]
+	if !ok || c.expired(e) {
+		return "", "", false
+	}
+	return e.URL, e.DatasetID, true
+}
+
+// Set adds an upload to the cache and saves it to disk.
+// If the key had an upload of another dataset, like an expired one, the
+// previous entry is kept under a derived key so that prune still deletes its
+// dataset.
+func (c *Cache) Set(key, url, datasetID string) error {
+	return c.update(func(entries map[string]*Entry) {
+		if old, ok := entries[key]; ok && old.DatasetID != datasetID {
+			entries[key+":"+old.DatasetID] = old
+		}
+		entries[key] = &Entry{
+			URL:       url,
+			DatasetID: datasetID,
+			CreatedAt: time.Now().UTC(),
+		}
+	})
+}
+
+// Delete removes an upload from the cache and saves it to disk.
+func (c *Cache) Delete(key string) error {
+	return c.update(func(entries map[string]*Entry) {
+		delete(entries, key)
+	})
+}
+
+// Entries returns a copy of the cached entries. If expiredOnly is true, only
+// the entries older than the ttl are returned.
+func (c *Cache) Entries(expiredOnly bool) map[string]Entry {
+	c.lck.Lock()
+	defer c.lck.Unlock()
+	entries := map[string]Entry{}
+	for k, e := range c.entries {
+		if expiredOnly && !c.expired(e) {
+			continue
+		}
+		entries[k] = *e
+	}
+	return entries
+}
+
+func (c *Cache) expired(e *Entry) bool {
+	return c.ttl > 0 && time.Since(e.CreatedAt) > c.ttl
+}
+
+// update reloads the file before applying the changes so that entries added
+// by other processes aren't lost.
+func (c *Cache) update(fn func(map[string]*Entry)) error {
+	c.lck.Lock()
+	defer c.lck.Unlock()
+	entries, err := c.load()
+	if err != nil {
+		return err
+	}
+	fn(entries)
+	if err := c.save(entries); err != nil {
+		return err
+	}
+	c.entries = entries
+	return nil
+}
+
+func (c *Cache) load() (map[string]*Entry, error) {
+	entries := map[string]*Entry{}
+	b, err := os.ReadFile(c.path)
+	if os.IsNotExist(err) {
+		return entries, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cache: couldn't read %s: %w", c.path, err)
+	}
+	if len(b) == 0 {
+		return entries, nil
+	}
+	if err := json.Unmarshal(b, &entries); err != nil {
+		return nil, fmt.Errorf("cache: couldn't unmarshal %s: %w", c.path, err)
+	}
+	return entries, nil
+}
+
+func (c *Cache) save(entries map[string]*Entry) error {
+	b, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return fmt.Errorf("cache: couldn't marshal entries: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0755); err != nil {
+		return fmt.Errorf("cache: couldn't create directory: %w", err)
+	}
+	// Write to a temp file and rename it to avoid corrupting the cache
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0644); err != nil {
+		return fmt.Errorf("cache: couldn't write %s: %w", tmp, err)
+	}
+	if err := os.Rename(tmp, c.path); err != nil {
+		return fmt.Errorf("cache: couldn't rename %s: %w", tmp, err)
+	}
+	return nil
+}
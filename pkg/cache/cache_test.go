@@ -0,0 +1,63 @@
+package cache
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "uploads.json")
+	c, err := Open(path, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Set("key", "https://a.url.test", "id"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Reopen the cache to check that entries are persisted
+	c, err = Open(path, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, id, ok := c.Get("key")
+	if !ok || u != "https://a.url.test" || id != "id" {
+		t.Fatalf("unexpected entry %q %q %v", u, id, ok)
+	}
+	if n := len(c.Entries(true)); n != 0 {
+		t.Errorf("expected 0 expired entries, got %d", n)
+	}
+
+	// Entries older than the ttl are expired
+	c.ttl = time.Nanosecond
+	time.Sleep(time.Millisecond)
+	if _, _, ok := c.Get("key"); ok {
+		t.Error("expected expired entry")
+	}
+	if n := len(c.Entries(true)); n != 1 {
+		t.Errorf("expected 1 expired entry, got %d", n)
+	}
+
+	// Replaced uploads are kept to be pruned
+	c.ttl = time.Hour
+	if err := c.Set("key", "https://b.url.test", "id2"); err != nil {
+		t.Fatal(err)
+	}
+	if u, id, ok := c.Get("key"); !ok || u != "https://b.url.test" || id != "id2" {
+		t.Fatalf("unexpected entry %q %q %v", u, id, ok)
+	}
+	stale, ok := c.Entries(false)["key:id"]
+	if !ok || stale.DatasetID != "id" {
+		t.Fatalf("expected replaced entry to be kept, got %+v", c.Entries(false))
+	}
+	if err := c.Delete("key:id"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Delete("key"); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(c.Entries(false)); n != 0 {
+		t.Errorf("expected 0 entries, got %d", n)
+	}
+}
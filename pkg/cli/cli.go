@@ -8,9 +8,18 @@ import (
 	"strings"
 	"time"
 
+	"github.com/igolaizola/vidai/pkg/cmd/audio"
+	"github.com/igolaizola/vidai/pkg/cmd/cancel"
+	"github.com/igolaizola/vidai/pkg/cmd/edit"
+	"github.com/igolaizola/vidai/pkg/cmd/export"
 	"github.com/igolaizola/vidai/pkg/cmd/extend"
 	"github.com/igolaizola/vidai/pkg/cmd/generate"
 	"github.com/igolaizola/vidai/pkg/cmd/loop"
+	"github.com/igolaizola/vidai/pkg/cmd/prune"
+	"github.com/igolaizola/vidai/pkg/cmd/sequence"
+	"github.com/igolaizola/vidai/pkg/cmd/serve"
+	"github.com/igolaizola/vidai/pkg/cmd/tasks"
+	"github.com/igolaizola/vidai/pkg/cmd/watch"
 	"github.com/peterbourgon/ff/v3"
 	"github.com/peterbourgon/ff/v3/ffcli"
 	"github.com/peterbourgon/ff/v3/ffyaml"
@@ -30,6 +39,15 @@ func NewCommand(version, commit, date string) *ffcli.Command {
 			newGenerateCommand(),
 			newExtendCommand(),
 			newLoopCommand(),
+			newExportCommand(),
+			newEditCommand(),
+			newAudioCommand(),
+			newSequenceCommand(),
+			newServeCommand(),
+			newWatchCommand(),
+			newTasksCommand(),
+			newCancelCommand(),
+			newCacheCommand(),
 		},
 	}
 }
@@ -74,10 +92,13 @@ func newGenerateCommand() *ffcli.Command {
 
 	fs.StringVar(&cfg.Model, "model", "gen3", "model to use (gen2, gen3, gen3-turbo)")
 	fs.StringVar(&cfg.Folder, "folder", "", "runway folder to store assets (optional)")
-	fs.StringVar(&cfg.Image, "image", "", "source image")
+	fs.StringVar(&cfg.Image, "image", "", "source image (path, url, - for stdin or asset:<id>)")
+	fs.StringVar(&cfg.Video, "video", "", "source video to use for video to video or to extend natively (path, url, - for stdin or asset:<id>) (optional)")
 	fs.StringVar(&cfg.Text, "text", "", "source text")
 	fs.StringVar(&cfg.Output, "output", "", "output file (optional, if omitted it won't be saved)")
 	fs.IntVar(&cfg.Extend, "extend", 0, "extend the video by this many times (optional)")
+	fs.StringVar(&cfg.Prompts, "prompts", "", "text prompts for each extension separated by semicolons, with optional options after a pipe (e.g. \"a;b | model=gen3-turbo seconds=10\") (optional)")
+	fs.StringVar(&cfg.PromptFile, "prompt-file", "", "file with a text prompt for each extension, one per line (optional)")
 	fs.BoolVar(&cfg.Interpolate, "interpolate", true, "interpolate frames (optional)")
 	fs.BoolVar(&cfg.Upscale, "upscale", false, "upscale frames (optional)")
 	fs.BoolVar(&cfg.Watermark, "watermark", false, "add watermark (optional)")
@@ -87,6 +108,19 @@ func newGenerateCommand() *ffcli.Command {
 	fs.BoolVar(&cfg.Explore, "explore", false, "explore mode (optional)")
 	fs.BoolVar(&cfg.LastFrame, "last-frame", false, "use source image as the last frame (optional)")
 	fs.IntVar(&cfg.Seconds, "seconds", 10, "duration of the video in seconds (optional)")
+	fs.StringVar(&cfg.Fit, "fit", "crop", "how to adapt the image to the video aspect ratio (crop, pad, stretch, none)")
+	fs.BoolVar(&cfg.NoPreprocess, "no-preprocess", false, "upload the image as is, without converting or resizing it (optional)")
+	fs.StringVar(&cfg.FFmpeg, "ffmpeg", "ffmpeg", "path to the ffmpeg binary (ffprobe is expected next to it)")
+	fs.StringVar(&cfg.Format, "format", "mp4", "output format (mp4, gif, webm, apng, frames)")
+	fs.StringVar(&cfg.Audio, "audio", "", "audio track added to the output, looped or trimmed to the video length (optional)")
+	fs.DurationVar(&cfg.AudioFade, "audio-fade", 0, "fade in and fade out duration of the audio track (optional)")
+	fs.StringVar(&cfg.Post, "post", "", "edit operations applied to the output, see edit command (optional)")
+	fs.BoolVar(&cfg.KeepUploads, "keep-uploads", false, "keep uploaded assets and reuse them in next runs (optional)")
+	fs.StringVar(&cfg.UploadCache, "upload-cache", "", "upload cache file (optional, defaults to user cache dir)")
+	fs.DurationVar(&cfg.UploadCacheTTL, "upload-cache-ttl", 24*time.Hour, "time to reuse cached uploads (optional)")
+	fs.StringVar(&cfg.NotifyURL, "notify-url", "", "url where a json payload is posted when the run finishes (optional)")
+	fs.StringVar(&cfg.NotifySecret, "notify-secret", "", "secret used to sign the notify payload in the X-Vidai-Signature header (optional)")
+	fs.StringVar(&cfg.OnComplete, "on-complete", "", "command launched when the run succeeds, {output}, {task_id} and {url} are replaced (optional)")
 
 	return &ffcli.Command{
 		Name:       cmd,
@@ -113,9 +147,11 @@ func newExtendCommand() *ffcli.Command {
 	fs.BoolVar(&cfg.Debug, "debug", false, "debug mode")
 	fs.DurationVar(&cfg.Wait, "wait", 2*time.Second, "wait time between requests")
 	fs.StringVar(&cfg.Token, "token", "", "runway token")
-	fs.StringVar(&cfg.Input, "input", "", "input video")
+	fs.StringVar(&cfg.Input, "input", "", "input video (path, url, - for stdin or asset:<id>)")
 	fs.StringVar(&cfg.Output, "output", "", "output file (optional, if omitted it won't be saved)")
 	fs.IntVar(&cfg.N, "n", 1, "extend the video by this many times")
+	fs.StringVar(&cfg.Prompts, "prompts", "", "text prompts for each extension separated by semicolons, with optional options after a pipe (e.g. \"a;b | model=gen3-turbo seconds=10\") (optional)")
+	fs.StringVar(&cfg.PromptFile, "prompt-file", "", "file with a text prompt for each extension, one per line (optional)")
 	fs.StringVar(&cfg.Model, "model", "gen2", "model to use (gen2 or gen3)")
 	fs.StringVar(&cfg.Folder, "folder", "", "runway folder to store assets (optional)")
 	fs.BoolVar(&cfg.Interpolate, "interpolate", true, "interpolate frames (optional)")
@@ -123,6 +159,26 @@ func newExtendCommand() *ffcli.Command {
 	fs.BoolVar(&cfg.Watermark, "watermark", false, "add watermark (optional)")
 	fs.BoolVar(&cfg.Explore, "explore", false, "explore mode (optional)")
 	fs.IntVar(&cfg.Seconds, "seconds", 2, "duration of the video in seconds (optional)")
+	fs.StringVar(&cfg.FFmpeg, "ffmpeg", "ffmpeg", "path to the ffmpeg binary (ffprobe is expected next to it)")
+	fs.StringVar(&cfg.Format, "format", "mp4", "output format (mp4, gif, webm, apng, frames)")
+	fs.StringVar(&cfg.Audio, "audio", "", "audio track added to the output, looped or trimmed to the video length (optional)")
+	fs.DurationVar(&cfg.AudioFade, "audio-fade", 0, "fade in and fade out duration of the audio track (optional)")
+	fs.BoolVar(&cfg.AudioCover, "audio-cover", false, "add extensions until the video covers the audio track (optional)")
+	fs.StringVar(&cfg.Normalize, "normalize", "auto", "normalize segments before joining them (auto, always, never)")
+	fs.IntVar(&cfg.TrimFrames, "trim-frames", 1, "frames to trim from the start of each generated segment to avoid duplicated seam frames")
+	fs.DurationVar(&cfg.Crossfade, "crossfade", 0, "crossfade duration between segments (optional, e.g. 0.25s)")
+	fs.BoolVar(&cfg.ColorMatch, "color-match", false, "match the colors of each segment to the end of the previous one (optional)")
+	fs.StringVar(&cfg.SeedFrame, "seed-frame", "last", "frame used to generate the next segment (last, sharpest, offset=-0.5s)")
+	fs.IntVar(&cfg.Candidates, "candidates", 1, "number of takes generated concurrently for each step (optional)")
+	fs.StringVar(&cfg.Pick, "pick", "interactive", "how to pick a take when there are several candidates (interactive, sharpness, motion, file=<path>)")
+	fs.StringVar(&cfg.TakesDir, "takes-dir", "", "directory to keep the takes of each step (optional, defaults to <output>-takes)")
+	fs.StringVar(&cfg.KeepSegments, "keep-segments", "", "directory to keep the segments and their manifest (optional)")
+	fs.StringVar(&cfg.Manifest, "manifest", "", "rebuild the output from the segments of a manifest without generating them again (optional)")
+	fs.StringVar(&cfg.WorkDir, "workdir", "", "directory where the temp workspace of each run is created (optional, defaults to the system temp dir)")
+	fs.BoolVar(&cfg.KeepTemp, "keep-temp", false, "keep the temp workspace for debugging (optional)")
+	fs.StringVar(&cfg.NotifyURL, "notify-url", "", "url where a json payload is posted when the run finishes (optional)")
+	fs.StringVar(&cfg.NotifySecret, "notify-secret", "", "secret used to sign the notify payload in the X-Vidai-Signature header (optional)")
+	fs.StringVar(&cfg.OnComplete, "on-complete", "", "command launched when the run succeeds, {output}, {task_id} and {url} are replaced (optional)")
 
 	return &ffcli.Command{
 		Name:       cmd,
@@ -145,8 +201,33 @@ func newLoopCommand() *ffcli.Command {
 	fs := flag.NewFlagSet(cmd, flag.ExitOnError)
 	_ = fs.String("config", "", "config file (optional)")
 
-	input := fs.String("input", "", "input video")
-	output := fs.String("output", "", "output file")
+	var cfg loop.Config
+	fs.BoolVar(&cfg.Debug, "debug", false, "debug mode")
+	fs.DurationVar(&cfg.Wait, "wait", 2*time.Second, "wait time between requests")
+	fs.StringVar(&cfg.Token, "token", "", "runway token (only for ai loops)")
+	fs.StringVar(&cfg.Input, "input", "", "input video (or image for ai loops)")
+	fs.StringVar(&cfg.Output, "output", "", "output file")
+	fs.StringVar(&cfg.FFmpeg, "ffmpeg", "ffmpeg", "path to the ffmpeg binary (ffprobe is expected next to it)")
+	fs.BoolVar(&cfg.Normalize, "normalize", false, "encode the input again before repeating it, only used in repeat mode (optional)")
+	fs.StringVar(&cfg.Format, "format", "mp4", "output format (mp4, gif, webm, apng, frames)")
+	fs.StringVar(&cfg.Audio, "audio", "", "audio track added to the output, looped or trimmed to the video length (optional)")
+	fs.DurationVar(&cfg.AudioFade, "audio-fade", 0, "fade in and fade out duration of the audio track (optional)")
+	fs.StringVar(&cfg.Mode, "mode", "pingpong", "loop mode (pingpong, crossfade, repeat)")
+	fs.DurationVar(&cfg.Crossfade, "crossfade", time.Second, "crossfade duration in crossfade mode or from the video into the ai bridge")
+	fs.BoolVar(&cfg.AI, "ai", false, "generate a bridge that ends on the first frame instead of using a loop mode (optional)")
+	fs.StringVar(&cfg.Model, "model", "gen3-turbo", "model to use for ai loops (gen3, gen3-turbo)")
+	fs.StringVar(&cfg.Folder, "folder", "", "runway folder to store assets (optional)")
+	fs.StringVar(&cfg.Text, "text", "", "text prompt for ai loops (optional)")
+	fs.IntVar(&cfg.Seconds, "seconds", 5, "duration of the ai bridge in seconds (optional)")
+	fs.BoolVar(&cfg.Explore, "explore", false, "explore mode (optional)")
+	fs.IntVar(&cfg.Repeat, "repeat", 1, "number of times the loop is repeated (optional)")
+	fs.DurationVar(&cfg.Duration, "duration", 0, "target duration reached by repeating the loop, overrides repeat (optional)")
+	fs.StringVar(&cfg.AudioMode, "audio-mode", "drop", "audio handling (drop, forward)")
+	fs.StringVar(&cfg.WorkDir, "workdir", "", "directory where the temp workspace of each run is created (optional, defaults to the system temp dir)")
+	fs.BoolVar(&cfg.KeepTemp, "keep-temp", false, "keep the temp workspace for debugging (optional)")
+	fs.StringVar(&cfg.NotifyURL, "notify-url", "", "url where a json payload is posted when the run finishes (optional)")
+	fs.StringVar(&cfg.NotifySecret, "notify-secret", "", "secret used to sign the notify payload in the X-Vidai-Signature header (optional)")
+	fs.StringVar(&cfg.OnComplete, "on-complete", "", "command launched when the run succeeds, {output}, {task_id} and {url} are replaced (optional)")
 
 	return &ffcli.Command{
 		Name:       cmd,
@@ -159,16 +240,358 @@ func newLoopCommand() *ffcli.Command {
 		ShortHelp: fmt.Sprintf("vidai %s command", cmd),
 		FlagSet:   fs,
 		Exec: func(ctx context.Context, args []string) error {
-			if *input == "" {
-				return fmt.Errorf("input is required")
-			}
-			if *output == "" {
-				return fmt.Errorf("output is required")
-			}
-			if err := loop.Run(ctx, *input, *output); err != nil {
-				return err
+			return loop.Run(ctx, &cfg)
+		},
+	}
+}
+
+func newExportCommand() *ffcli.Command {
+	cmd := "export"
+	fs := flag.NewFlagSet(cmd, flag.ExitOnError)
+	_ = fs.String("config", "", "config file (optional)")
+
+	var cfg export.Config
+	fs.BoolVar(&cfg.Debug, "debug", false, "debug mode")
+	fs.StringVar(&cfg.Input, "input", "", "input video")
+	fs.StringVar(&cfg.Output, "output", "", "output file or directory for frames (optional, defaults to the input with the format extension)")
+	fs.StringVar(&cfg.Format, "format", "gif", "output format (mp4, gif, webm, apng, frames)")
+	fs.Float64Var(&cfg.FPS, "fps", 0, "output frame rate (optional)")
+	fs.IntVar(&cfg.Width, "width", 0, "output width, keeps the aspect ratio if height isn't set (optional)")
+	fs.IntVar(&cfg.Height, "height", 0, "output height, keeps the aspect ratio if width isn't set (optional)")
+	fs.StringVar(&cfg.FFmpeg, "ffmpeg", "ffmpeg", "path to the ffmpeg binary (ffprobe is expected next to it)")
+
+	return &ffcli.Command{
+		Name:       cmd,
+		ShortUsage: fmt.Sprintf("vidai %s [flags] <key> <value data...>", cmd),
+		Options: []ff.Option{
+			ff.WithConfigFileFlag("config"),
+			ff.WithConfigFileParser(ffyaml.Parser),
+			ff.WithEnvVarPrefix("VIDAI"),
+		},
+		ShortHelp: fmt.Sprintf("vidai %s command", cmd),
+		FlagSet:   fs,
+		Exec: func(ctx context.Context, args []string) error {
+			return export.Run(ctx, &cfg)
+		},
+	}
+}
+
+func newEditCommand() *ffcli.Command {
+	cmd := "edit"
+	fs := flag.NewFlagSet(cmd, flag.ExitOnError)
+	_ = fs.String("config", "", "config file (optional)")
+
+	var cfg edit.Config
+	fs.BoolVar(&cfg.Debug, "debug", false, "debug mode")
+	fs.StringVar(&cfg.Input, "input", "", "input video")
+	fs.StringVar(&cfg.Output, "output", "", "output file")
+	fs.StringVar(&cfg.Ops, "ops", "", "edit operations separated by commas: trim=start:end, speed=factor, scale=WxH, fill=WxH, reverse, mute")
+	fs.StringVar(&cfg.FFmpeg, "ffmpeg", "ffmpeg", "path to the ffmpeg binary (ffprobe is expected next to it)")
+
+	return &ffcli.Command{
+		Name:       cmd,
+		ShortUsage: fmt.Sprintf("vidai %s [flags] <key> <value data...>", cmd),
+		Options: []ff.Option{
+			ff.WithConfigFileFlag("config"),
+			ff.WithConfigFileParser(ffyaml.Parser),
+			ff.WithEnvVarPrefix("VIDAI"),
+		},
+		ShortHelp: fmt.Sprintf("vidai %s command", cmd),
+		FlagSet:   fs,
+		Exec: func(ctx context.Context, args []string) error {
+			return edit.Run(ctx, &cfg)
+		},
+	}
+}
+
+func newAudioCommand() *ffcli.Command {
+	cmd := "audio"
+	fs := flag.NewFlagSet(cmd, flag.ExitOnError)
+	_ = fs.String("config", "", "config file (optional)")
+
+	var cfg audio.Config
+	fs.BoolVar(&cfg.Debug, "debug", false, "debug mode")
+	fs.DurationVar(&cfg.Wait, "wait", 2*time.Second, "wait time between requests")
+	fs.StringVar(&cfg.Token, "token", "", "runway token (only to extend the video)")
+	fs.StringVar(&cfg.Input, "input", "", "input video")
+	fs.StringVar(&cfg.Audio, "audio", "", "audio track")
+	fs.StringVar(&cfg.Output, "output", "", "output file")
+	fs.DurationVar(&cfg.Fade, "fade", 0, "fade in and fade out duration of the audio (optional)")
+	fs.BoolVar(&cfg.NoLoop, "no-loop", false, "leave the end of the video silent instead of looping a shorter audio (optional)")
+	fs.StringVar(&cfg.FFmpeg, "ffmpeg", "ffmpeg", "path to the ffmpeg binary (ffprobe is expected next to it)")
+	fs.BoolVar(&cfg.Extend, "extend", false, "extend the video until it covers the audio (optional)")
+	fs.StringVar(&cfg.Model, "model", "gen2", "model to use to extend the video (gen2 or gen3)")
+	fs.StringVar(&cfg.Folder, "folder", "", "runway folder to store assets (optional)")
+	fs.IntVar(&cfg.Seconds, "seconds", 4, "duration of each extension in seconds (optional)")
+	fs.BoolVar(&cfg.Explore, "explore", false, "explore mode (optional)")
+
+	return &ffcli.Command{
+		Name:       cmd,
+		ShortUsage: fmt.Sprintf("vidai %s [flags] <key> <value data...>", cmd),
+		Options: []ff.Option{
+			ff.WithConfigFileFlag("config"),
+			ff.WithConfigFileParser(ffyaml.Parser),
+			ff.WithEnvVarPrefix("VIDAI"),
+		},
+		ShortHelp: fmt.Sprintf("vidai %s command", cmd),
+		FlagSet:   fs,
+		Exec: func(ctx context.Context, args []string) error {
+			return audio.Run(ctx, &cfg)
+		},
+	}
+}
+
+func newSequenceCommand() *ffcli.Command {
+	cmd := "sequence"
+	fs := flag.NewFlagSet(cmd, flag.ExitOnError)
+	_ = fs.String("config", "", "config file (optional)")
+
+	var cfg sequence.Config
+	fs.BoolVar(&cfg.Debug, "debug", false, "debug mode")
+	fs.DurationVar(&cfg.Wait, "wait", 2*time.Second, "wait time between requests")
+	fs.StringVar(&cfg.Token, "token", "", "runway token")
+	fs.StringVar(&cfg.Storyboard, "storyboard", "", "storyboard yaml file, it can also be passed as argument")
+	fs.StringVar(&cfg.Output, "output", "", "output file (optional, defaults to the storyboard output)")
+	fs.StringVar(&cfg.Model, "model", "gen3", "default model of the shots (gen2, gen3, gen3-turbo)")
+	fs.IntVar(&cfg.Seconds, "seconds", 5, "default duration of the shots in seconds")
+	fs.StringVar(&cfg.Folder, "folder", "", "runway folder to store assets (optional)")
+	fs.BoolVar(&cfg.Explore, "explore", false, "explore mode (optional)")
+	fs.StringVar(&cfg.ShotsDir, "shots-dir", "", "directory where generated shots are cached (optional, defaults to <output>-shots)")
+	fs.BoolVar(&cfg.Force, "force", false, "generate all the shots again even if they are cached (optional)")
+	fs.StringVar(&cfg.FFmpeg, "ffmpeg", "ffmpeg", "path to the ffmpeg binary (ffprobe is expected next to it)")
+	fs.StringVar(&cfg.Format, "format", "mp4", "output format (mp4, gif, webm, apng, frames)")
+	fs.StringVar(&cfg.Audio, "audio", "", "audio track added to the output, looped or trimmed to the video length (optional)")
+	fs.DurationVar(&cfg.AudioFade, "audio-fade", 0, "fade in and fade out duration of the audio track (optional)")
+	fs.StringVar(&cfg.WorkDir, "workdir", "", "directory where the temp workspace of each run is created (optional, defaults to the system temp dir)")
+	fs.BoolVar(&cfg.KeepTemp, "keep-temp", false, "keep the temp workspace for debugging (optional)")
+	fs.StringVar(&cfg.NotifyURL, "notify-url", "", "url where a json payload is posted when the run finishes (optional)")
+	fs.StringVar(&cfg.NotifySecret, "notify-secret", "", "secret used to sign the notify payload in the X-Vidai-Signature header (optional)")
+	fs.StringVar(&cfg.OnComplete, "on-complete", "", "command launched when the run succeeds, {output}, {task_id} and {url} are replaced (optional)")
+
+	return &ffcli.Command{
+		Name:       cmd,
+		ShortUsage: fmt.Sprintf("vidai %s [flags] <storyboard.yaml>", cmd),
+		Options: []ff.Option{
+			ff.WithConfigFileFlag("config"),
+			ff.WithConfigFileParser(ffyaml.Parser),
+			ff.WithEnvVarPrefix("VIDAI"),
+		},
+		ShortHelp: fmt.Sprintf("vidai %s command", cmd),
+		FlagSet:   fs,
+		Exec: func(ctx context.Context, args []string) error {
+			if len(args) > 0 {
+				cfg.Storyboard = args[0]
 			}
-			return nil
+			return sequence.Run(ctx, &cfg)
+		},
+	}
+}
+
+func newServeCommand() *ffcli.Command {
+	cmd := "serve"
+	fs := flag.NewFlagSet(cmd, flag.ExitOnError)
+	_ = fs.String("config", "", "config file (optional)")
+
+	var cfg serve.Config
+	fs.BoolVar(&cfg.Debug, "debug", false, "debug mode")
+	fs.DurationVar(&cfg.Wait, "wait", 2*time.Second, "wait time between requests")
+	fs.StringVar(&cfg.Token, "token", "", "runway token")
+	fs.StringVar(&cfg.Folder, "folder", "", "runway folder to store assets (optional)")
+	fs.StringVar(&cfg.Addr, "addr", "127.0.0.1:8080", "address to listen on, an api key is required if it isn't a loopback address")
+	fs.StringVar(&cfg.APIKey, "api-key", "", "api key required as bearer token in requests (optional on loopback addresses)")
+	fs.StringVar(&cfg.InputDir, "input-dir", "", "directory with the local files that jobs can use as sources (optional, if omitted local paths are rejected)")
+	fs.BoolVar(&cfg.AllowURLs, "allow-urls", false, "allow http(s) urls as sources, the server downloads them (optional)")
+	fs.StringVar(&cfg.Dir, "dir", "", "directory to persist jobs and their outputs (optional, if omitted jobs are kept in memory)")
+	fs.IntVar(&cfg.Concurrency, "concurrency", 1, "number of jobs run at the same time")
+	fs.StringVar(&cfg.FFmpeg, "ffmpeg", "ffmpeg", "path to the ffmpeg binary (ffprobe is expected next to it)")
+	fs.StringVar(&cfg.NotifyURL, "notify-url", "", "url where a json payload is posted when the run finishes (optional)")
+	fs.StringVar(&cfg.NotifySecret, "notify-secret", "", "secret used to sign the notify payload in the X-Vidai-Signature header (optional)")
+	fs.StringVar(&cfg.OnComplete, "on-complete", "", "command launched when the run succeeds, {output}, {task_id} and {url} are replaced (optional)")
+
+	return &ffcli.Command{
+		Name:       cmd,
+		ShortUsage: fmt.Sprintf("vidai %s [flags] <key> <value data...>", cmd),
+		Options: []ff.Option{
+			ff.WithConfigFileFlag("config"),
+			ff.WithConfigFileParser(ffyaml.Parser),
+			ff.WithEnvVarPrefix("VIDAI"),
+		},
+		ShortHelp: fmt.Sprintf("vidai %s command", cmd),
+		FlagSet:   fs,
+		Exec: func(ctx context.Context, args []string) error {
+			return serve.Run(ctx, &cfg)
+		},
+	}
+}
+
+func newWatchCommand() *ffcli.Command {
+	cmd := "watch"
+	fs := flag.NewFlagSet(cmd, flag.ExitOnError)
+	_ = fs.String("config", "", "config file (optional)")
+
+	var cfg watch.Config
+	fs.BoolVar(&cfg.Debug, "debug", false, "debug mode")
+	fs.DurationVar(&cfg.Wait, "wait", 2*time.Second, "wait time between requests")
+	fs.StringVar(&cfg.Token, "token", "", "runway token")
+	fs.StringVar(&cfg.Folder, "folder", "", "runway folder to store assets (optional)")
+	fs.BoolVar(&cfg.Explore, "explore", false, "explore mode (optional)")
+	fs.StringVar(&cfg.Dir, "dir", "", "directory watched for new images")
+	fs.StringVar(&cfg.Out, "out", "", "directory where the videos are saved")
+	fs.StringVar(&cfg.DoneDir, "done-dir", "", "directory where processed images are moved (optional, defaults to <dir>/done)")
+	fs.StringVar(&cfg.FailedDir, "failed-dir", "", "directory where failed images are moved (optional, defaults to <dir>/failed)")
+	fs.StringVar(&cfg.State, "state", "", "state file to resume after restarts (optional, defaults to <dir>/.vidai-watch.json)")
+	fs.DurationVar(&cfg.Interval, "interval", 5*time.Second, "polling interval, images are processed once they don't change between two polls")
+	fs.BoolVar(&cfg.Once, "once", false, "process the images in the directory and exit (optional)")
+	fs.StringVar(&cfg.Text, "text", "", "default text prompt, overridden by sidecar files (optional)")
+	fs.StringVar(&cfg.Model, "model", "gen3", "default model (gen2, gen3, gen3-turbo)")
+	fs.IntVar(&cfg.Seconds, "seconds", 10, "default duration of the videos in seconds")
+	fs.IntVar(&cfg.Extend, "extend", 0, "default number of extensions (optional)")
+	fs.StringVar(&cfg.Fit, "fit", "crop", "how to adapt the images to the video aspect ratio (crop, pad, stretch, none)")
+	fs.StringVar(&cfg.Format, "format", "mp4", "output format (mp4, gif, webm, apng, frames)")
+	fs.StringVar(&cfg.FFmpeg, "ffmpeg", "ffmpeg", "path to the ffmpeg binary (ffprobe is expected next to it)")
+	fs.StringVar(&cfg.NotifyURL, "notify-url", "", "url where a json payload is posted when each video finishes (optional)")
+	fs.StringVar(&cfg.NotifySecret, "notify-secret", "", "secret used to sign the notify payload in the X-Vidai-Signature header (optional)")
+	fs.StringVar(&cfg.OnComplete, "on-complete", "", "command launched when each video succeeds, {output}, {task_id} and {url} are replaced (optional)")
+
+	return &ffcli.Command{
+		Name:       cmd,
+		ShortUsage: fmt.Sprintf("vidai %s [flags] <key> <value data...>", cmd),
+		Options: []ff.Option{
+			ff.WithConfigFileFlag("config"),
+			ff.WithConfigFileParser(ffyaml.Parser),
+			ff.WithEnvVarPrefix("VIDAI"),
+		},
+		ShortHelp: fmt.Sprintf("vidai %s command", cmd),
+		FlagSet:   fs,
+		Exec: func(ctx context.Context, args []string) error {
+			return watch.Run(ctx, &cfg)
+		},
+	}
+}
+
+func newTasksCommand() *ffcli.Command {
+	cmd := "tasks"
+	fs := flag.NewFlagSet(cmd, flag.ExitOnError)
+
+	return &ffcli.Command{
+		Name:       cmd,
+		ShortUsage: fmt.Sprintf("vidai %s <subcommand>", cmd),
+		ShortHelp:  fmt.Sprintf("vidai %s commands", cmd),
+		FlagSet:    fs,
+		Exec: func(context.Context, []string) error {
+			return flag.ErrHelp
+		},
+		Subcommands: []*ffcli.Command{
+			newTasksListCommand(),
+		},
+	}
+}
+
+func newTasksListCommand() *ffcli.Command {
+	cmd := "list"
+	fs := flag.NewFlagSet(cmd, flag.ExitOnError)
+	_ = fs.String("config", "", "config file (optional)")
+
+	var cfg tasks.Config
+	fs.BoolVar(&cfg.Debug, "debug", false, "debug mode")
+	fs.DurationVar(&cfg.Wait, "wait", 2*time.Second, "wait time between requests")
+	fs.StringVar(&cfg.Token, "token", "", "runway token")
+
+	fs.StringVar(&cfg.Since, "since", "", "list tasks created after this duration ago (e.g. 24h) or date (e.g. 2024-01-31) (optional)")
+	fs.StringVar(&cfg.Until, "until", "", "list tasks created before this duration ago or date (optional)")
+	fs.StringVar(&cfg.Status, "status", "", "filter by status (e.g. SUCCEEDED, FAILED, RUNNING) (optional)")
+	fs.StringVar(&cfg.TaskType, "type", "", "filter by task type (e.g. gen2, gen3a, gen3a_turbo) (optional)")
+	fs.IntVar(&cfg.Limit, "limit", 0, "maximum number of tasks to list (optional)")
+	fs.StringVar(&cfg.Format, "format", "table", "output format (json, csv, table)")
+	fs.BoolVar(&cfg.Summary, "summary", false, "print a per day summary instead of the task list (optional)")
+
+	return &ffcli.Command{
+		Name:       cmd,
+		ShortUsage: fmt.Sprintf("vidai tasks %s [flags] <key> <value data...>", cmd),
+		Options: []ff.Option{
+			ff.WithConfigFileFlag("config"),
+			ff.WithConfigFileParser(ffyaml.Parser),
+			ff.WithEnvVarPrefix("VIDAI"),
+		},
+		ShortHelp: fmt.Sprintf("vidai tasks %s command", cmd),
+		FlagSet:   fs,
+		Exec: func(ctx context.Context, args []string) error {
+			return tasks.Run(ctx, &cfg)
+		},
+	}
+}
+
+func newCancelCommand() *ffcli.Command {
+	cmd := "cancel"
+	fs := flag.NewFlagSet(cmd, flag.ExitOnError)
+	_ = fs.String("config", "", "config file (optional)")
+
+	var cfg cancel.Config
+	fs.BoolVar(&cfg.Debug, "debug", false, "debug mode")
+	fs.DurationVar(&cfg.Wait, "wait", 2*time.Second, "wait time between requests")
+	fs.StringVar(&cfg.Token, "token", "", "runway token")
+
+	return &ffcli.Command{
+		Name:       cmd,
+		ShortUsage: fmt.Sprintf("vidai %s [flags] <task-id...>", cmd),
+		Options: []ff.Option{
+			ff.WithConfigFileFlag("config"),
+			ff.WithConfigFileParser(ffyaml.Parser),
+			ff.WithEnvVarPrefix("VIDAI"),
+		},
+		ShortHelp: fmt.Sprintf("vidai %s command", cmd),
+		FlagSet:   fs,
+		Exec: func(ctx context.Context, args []string) error {
+			cfg.TaskIDs = args
+			return cancel.Run(ctx, &cfg)
+		},
+	}
+}
+
+func newCacheCommand() *ffcli.Command {
+	cmd := "cache"
+	fs := flag.NewFlagSet(cmd, flag.ExitOnError)
+
+	return &ffcli.Command{
+		Name:       cmd,
+		ShortUsage: fmt.Sprintf("vidai %s <subcommand>", cmd),
+		ShortHelp:  fmt.Sprintf("vidai %s commands", cmd),
+		FlagSet:    fs,
+		Exec: func(context.Context, []string) error {
+			return flag.ErrHelp
+		},
+		Subcommands: []*ffcli.Command{
+			newCachePruneCommand(),
+		},
+	}
+}
+
+func newCachePruneCommand() *ffcli.Command {
+	cmd := "prune"
+	fs := flag.NewFlagSet(cmd, flag.ExitOnError)
+	_ = fs.String("config", "", "config file (optional)")
+
+	var cfg prune.Config
+	fs.BoolVar(&cfg.Debug, "debug", false, "debug mode")
+	fs.DurationVar(&cfg.Wait, "wait", 2*time.Second, "wait time between requests")
+	fs.StringVar(&cfg.Token, "token", "", "runway token")
+
+	fs.StringVar(&cfg.UploadCache, "upload-cache", "", "upload cache file (optional, defaults to user cache dir)")
+	fs.DurationVar(&cfg.UploadCacheTTL, "upload-cache-ttl", 24*time.Hour, "uploads older than this are pruned (optional)")
+	fs.BoolVar(&cfg.All, "all", false, "prune all cached uploads, not only expired ones (optional)")
+
+	return &ffcli.Command{
+		Name:       cmd,
+		ShortUsage: fmt.Sprintf("vidai cache %s [flags] <key> <value data...>", cmd),
+		Options: []ff.Option{
+			ff.WithConfigFileFlag("config"),
+			ff.WithConfigFileParser(ffyaml.Parser),
+			ff.WithEnvVarPrefix("VIDAI"),
+		},
+		ShortHelp: fmt.Sprintf("vidai cache %s command", cmd),
+		FlagSet:   fs,
+		Exec: func(ctx context.Context, args []string) error {
+			return prune.Run(ctx, &cfg)
 		},
 	}
 }
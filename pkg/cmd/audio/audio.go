@@ -0,0 +1,97 @@
+package audio
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/igolaizola/vidai/pkg/cmd/extend"
+	"github.com/igolaizola/vidai/pkg/ffmpeg"
+)
+
+type Config struct {
+	Token string
+	Wait  time.Duration
+	Debug bool
+	Proxy string
+
+	Input  string
+	Audio  string
+	Output string
+	Fade   time.Duration
+	NoLoop bool
+	FFmpeg string
+
+	// Extend generates extensions until the video covers the audio.
+	Extend  bool
+	Model   string
+	Folder  string
+	Seconds int
+	Explore bool
+}
+
+// Run adds an audio track to a video. The audio is looped or trimmed to the
+// video length, unless the video is extended to cover the audio.
+func Run(ctx context.Context, cfg *Config) error {
+	if cfg.Input == "" {
+		return fmt.Errorf("input is required")
+	}
+	if cfg.Audio == "" {
+		return fmt.Errorf("audio is required")
+	}
+	if cfg.Output == "" {
+		return fmt.Errorf("output is required")
+	}
+
+	ff := ffmpeg.New(&ffmpeg.Config{
+		Bin:   cfg.FFmpeg,
+		Debug: cfg.Debug,
+	})
+
+	// Extend the video and add the audio to the result if the video is
+	// shorter than the audio
+	extendVideo := false
+	if cfg.Extend {
+		vinfo, err := ff.Probe(ctx, cfg.Input)
+		if err != nil {
+			return err
+		}
+		ainfo, err := ff.Probe(ctx, cfg.Audio)
+		if err != nil {
+			return err
+		}
+		extendVideo = vinfo.Duration < ainfo.Duration
+	}
+	if extendVideo {
+		return extend.Run(ctx, &extend.Config{
+			Token:       cfg.Token,
+			Wait:        cfg.Wait,
+			Debug:       cfg.Debug,
+			Proxy:       cfg.Proxy,
+			Input:       cfg.Input,
+			Output:      cfg.Output,
+			N:           1,
+			Model:       cfg.Model,
+			Folder:      cfg.Folder,
+			Interpolate: true,
+			Explore:     cfg.Explore,
+			Seconds:     cfg.Seconds,
+			FFmpeg:      cfg.FFmpeg,
+			Normalize:   ffmpeg.NormalizeAuto,
+			TrimFrames:  1,
+			Audio:       cfg.Audio,
+			AudioFade:   cfg.Fade,
+			AudioCover:  true,
+			AudioNoLoop: cfg.NoLoop,
+		})
+	}
+
+	if err := ff.AddAudio(ctx, cfg.Input, cfg.Audio, cfg.Output, &ffmpeg.AudioOptions{
+		FadeIn:  cfg.Fade,
+		FadeOut: cfg.Fade,
+		NoLoop:  cfg.NoLoop,
+	}); err != nil {
+		return fmt.Errorf("vidai: couldn't add audio: %w", err)
+	}
+	return nil
+}
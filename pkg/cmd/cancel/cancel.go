@@ -0,0 +1,44 @@
+package cancel
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/igolaizola/vidai/pkg/runway"
+)
+
+type Config struct {
+	Token string
+	Wait  time.Duration
+	Debug bool
+	Proxy string
+
+	TaskIDs []string
+}
+
+// Run cancels running tasks.
+func Run(ctx context.Context, cfg *Config) error {
+	if len(cfg.TaskIDs) == 0 {
+		return fmt.Errorf("vidai: task id is required")
+	}
+	if cfg.Token == "" {
+		return fmt.Errorf("token is required")
+	}
+	client, err := runway.New(&runway.Config{
+		Token: cfg.Token,
+		Wait:  cfg.Wait,
+		Debug: cfg.Debug,
+		Proxy: cfg.Proxy,
+	})
+	if err != nil {
+		return fmt.Errorf("vidai: couldn't create client: %w", err)
+	}
+	for _, id := range cfg.TaskIDs {
+		if err := client.CancelTask(ctx, id); err != nil {
+			return fmt.Errorf("vidai: couldn't cancel task: %w", err)
+		}
+		fmt.Printf("task %s cancelled\n", id)
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package edit
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/igolaizola/vidai/pkg/ffmpeg"
+)
+
+type Config struct {
+	Debug bool
+
+	Input  string
+	Output string
+	Ops    string
+	FFmpeg string
+}
+
+// Run applies a pipeline of edit operations to a video.
+func Run(ctx context.Context, cfg *Config) error {
+	if cfg.Input == "" {
+		return fmt.Errorf("input is required")
+	}
+	if cfg.Output == "" {
+		return fmt.Errorf("output is required")
+	}
+	if cfg.Output == cfg.Input {
+		return fmt.Errorf("vidai: output and input are the same file")
+	}
+	ops, err := ffmpeg.ParseOps(cfg.Ops)
+	if err != nil {
+		return fmt.Errorf("vidai: couldn't parse ops: %w", err)
+	}
+	ff := ffmpeg.New(&ffmpeg.Config{
+		Bin:   cfg.FFmpeg,
+		Debug: cfg.Debug,
+	})
+	if err := ff.Edit(ctx, cfg.Input, cfg.Output, ops); err != nil {
+		return fmt.Errorf("vidai: couldn't edit video: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package export
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/igolaizola/vidai/pkg/ffmpeg"
+)
+
+type Config struct {
+	Debug bool
+
+	Input  string
+	Output string
+	Format string
+	FPS    float64
+	Width  int
+	Height int
+	FFmpeg string
+}
+
+// Run converts a video to another format.
+func Run(ctx context.Context, cfg *Config) error {
+	if cfg.Input == "" {
+		return fmt.Errorf("input is required")
+	}
+	format := cfg.Format
+	if format == "" {
+		format = ffmpeg.FormatGIF
+	}
+	output := cfg.Output
+	if output == "" {
+		output = strings.TrimSuffix(cfg.Input, filepath.Ext(cfg.Input)) + ffmpeg.Extension(format)
+		if format == ffmpeg.FormatFrames {
+			output = strings.TrimSuffix(cfg.Input, filepath.Ext(cfg.Input)) + "-frames"
+		}
+	}
+	if output == cfg.Input {
+		return fmt.Errorf("vidai: output and input are the same file")
+	}
+	ff := ffmpeg.New(&ffmpeg.Config{
+		Bin:   cfg.FFmpeg,
+		Debug: cfg.Debug,
+	})
+	if err := ff.Export(ctx, cfg.Input, output, &ffmpeg.ExportOptions{
+		Format: format,
+		FPS:    cfg.FPS,
+		Width:  cfg.Width,
+		Height: cfg.Height,
+	}); err != nil {
+		return fmt.Errorf("vidai: couldn't export video: %w", err)
+	}
+	fmt.Println(output)
+	return nil
+}
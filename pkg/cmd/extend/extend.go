@@ -1,17 +1,23 @@
 package extend
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"io"
 	"log"
+	"net/url"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
 	"time"
 
+	"github.com/igolaizola/vidai/pkg/ffmpeg"
+	"github.com/igolaizola/vidai/pkg/input"
+	"github.com/igolaizola/vidai/pkg/notify"
+	"github.com/igolaizola/vidai/pkg/prompts"
 	"github.com/igolaizola/vidai/pkg/runway"
+	"github.com/igolaizola/vidai/pkg/workspace"
 )
 
 type Config struct {
@@ -30,16 +36,71 @@ type Config struct {
 	Watermark   bool
 	Explore     bool
 	Seconds     int
+	FFmpeg      string
+	Normalize   string
+	TrimFrames  int
+	Crossfade   time.Duration
+	ColorMatch  bool
+	SeedFrame   string
+	Prompts     string
+	PromptFile  string
+	Candidates  int
+	Pick        string
+	TakesDir    string
+
+	KeepSegments string
+	Manifest     string
+	WorkDir      string
+	KeepTemp     bool
+	Format       string
+
+	Audio       string
+	AudioFade   time.Duration
+	AudioCover  bool
+	AudioNoLoop bool
+
+	NotifyURL    string
+	NotifySecret string
+	OnComplete   string
+	// Event collects the tasks of the run when it is part of another
+	// command (optional).
+	Event *notify.Event
 }
 
 // Run generates a video from an image and a text prompt.
 func Run(ctx context.Context, cfg *Config) error {
+	n, err := notify.New(&notify.Config{
+		URL:     cfg.NotifyURL,
+		Secret:  cfg.NotifySecret,
+		Command: cfg.OnComplete,
+		Debug:   cfg.Debug,
+	})
+	if err != nil {
+		return err
+	}
+	ev := &notify.Event{Command: "extend", Output: cfg.Output}
+	err = run(ctx, cfg, ev)
+	if cfg.Event != nil {
+		cfg.Event.Merge(ev)
+	}
+	n.Done(ctx, ev, err)
+	return err
+}
+
+func run(ctx context.Context, cfg *Config, ev *notify.Event) error {
+	// Rebuild the video from previous segments without generating them again
+	if cfg.Manifest != "" {
+		return rebuild(ctx, cfg)
+	}
 	if cfg.Input == "" {
 		return fmt.Errorf("input is required")
 	}
 	if cfg.N < 1 {
 		return fmt.Errorf("n must be greater than 0")
 	}
+	if cfg.Audio != "" && cfg.Output == "" {
+		return fmt.Errorf("output is required to add audio")
+	}
 	if cfg.Token == "" {
 		return fmt.Errorf("token is required")
 	}
@@ -54,31 +115,133 @@ func Run(ctx context.Context, cfg *Config) error {
 		return fmt.Errorf("vidai: couldn't create client: %w", err)
 	}
 
-	base := strings.TrimSuffix(filepath.Base(cfg.Input), filepath.Ext(cfg.Input))
+	steps, err := prompts.Load(cfg.Prompts, cfg.PromptFile)
+	if err != nil {
+		return fmt.Errorf("vidai: couldn't load prompts: %w", err)
+	}
+	// Each prompt is an extension step
+	n := cfg.N
+	if len(steps) > n {
+		n = len(steps)
+	}
 
-	// Copy input video to temp file
-	vid := filepath.Join(os.TempDir(), fmt.Sprintf("%s-0.mp4", base))
-	if err := copyFile(cfg.Input, vid); err != nil {
-		return fmt.Errorf("vidai: couldn't copy input video: %w", err)
+	seed, err := parseSeedFrame(cfg.SeedFrame)
+	if err != nil {
+		return err
+	}
+
+	// Temporary files are removed with the workspace even if the process is
+	// interrupted
+	ws, err := workspace.New(cfg.WorkDir, "extend", cfg.KeepTemp)
+	if err != nil {
+		return err
 	}
+	defer ws.Close()
 
+	ff := ffmpeg.New(&ffmpeg.Config{
+		Bin:     cfg.FFmpeg,
+		Debug:   cfg.Debug,
+		TempDir: ws.Dir(),
+	})
+
+	base := inputBase(cfg.Input)
+
+	// Segments are stored in the segments dir if they must be kept
+	dir := ws.Dir()
+	keep := cfg.KeepSegments != ""
+	if keep {
+		dir = cfg.KeepSegments
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("vidai: couldn't create segments dir: %w", err)
+		}
+	}
+
+	// Takes are kept on disk when several candidates are generated per step
+	var pick picker
+	takesDir := cfg.TakesDir
+	if cfg.Candidates > 1 {
+		pick, err = newPicker(cfg.Pick, ff, cfg.Input == "-")
+		if err != nil {
+			return err
+		}
+		if takesDir == "" {
+			takesDir = base + "-takes"
+			if cfg.Output != "" {
+				takesDir = strings.TrimSuffix(cfg.Output, filepath.Ext(cfg.Output)) + "-takes"
+			}
+		}
+		if err := os.MkdirAll(takesDir, 0o755); err != nil {
+			return fmt.Errorf("vidai: couldn't create takes dir: %w", err)
+		}
+	}
+
+	// Copy input video
+	vid := filepath.Join(dir, fmt.Sprintf("%s-0.mp4", base))
+	if err := copyInput(ctx, client, cfg.Input, vid); err != nil {
+		return fmt.Errorf("vidai: couldn't copy input video: %w", err)
+	}
 	videos := []string{vid}
-	var urls []string
-	for i := 0; i < cfg.N; i++ {
-		img := filepath.Join(os.TempDir(), fmt.Sprintf("%s-%d.jpg", base, i))
-
-		// Extract last frame from video using the following command:
-		// ffmpeg -sseof -1 -i input.mp4 -update 1 -q:v 1 output.jpg
-		// This will seek to the last second of the input and output all frames.
-		// But since -update 1 is set, each frame will be overwritten to the
-		// same file, leaving only the last frame remaining.
-		cmd := exec.CommandContext(ctx, "ffmpeg", "-sseof", "-1", "-i", vid, "-update", "1", "-q:v", "1", img)
-		cmdOut, err := cmd.CombinedOutput()
+
+	// Add extensions until the video covers the audio
+	var audioDuration time.Duration
+	cover := cfg.Audio != "" && cfg.AudioCover
+	if cover {
+		ainfo, err := ff.Probe(ctx, cfg.Audio)
+		if err != nil {
+			return err
+		}
+		audioDuration = ainfo.Duration
+		count, err := coverSteps(ctx, ff, vid, audioDuration, steps, cfg)
 		if err != nil {
-			return fmt.Errorf("vidai: couldn't extract last frame (%s): %w", string(cmdOut), err)
+			return err
 		}
+		if count > n {
+			log.Printf("vidai: extending %d times to cover the audio\n", count)
+			n = count
+		}
+	}
 
-		// Read image
+	manifest := &Manifest{Input: cfg.Input}
+	if keep {
+		if err := addSegment(ctx, ff, dir, manifest, Segment{Path: vid}); err != nil {
+			return err
+		}
+	}
+
+	var urls []string
+	var cuts []time.Duration
+	var joined time.Duration
+	for i := 0; ; i++ {
+		if i >= n {
+			if !cover {
+				break
+			}
+			// The estimate may fall short if segments are shorter than
+			// requested or cut before their end
+			d, err := joinedDuration(ctx, ff, videos, cuts, cfg)
+			if err != nil {
+				return err
+			}
+			if d >= audioDuration {
+				break
+			}
+			if d <= joined {
+				return fmt.Errorf("vidai: extensions don't make the video longer to cover the audio")
+			}
+			joined = d
+			log.Printf("vidai: video is %s and audio is %s, extending again\n", d.Round(time.Millisecond), audioDuration.Round(time.Millisecond))
+		}
+		img := filepath.Join(dir, fmt.Sprintf("%s-%d.jpg", base, i))
+
+		// Extract seed frame from video
+		cut, err := seed.extract(ctx, ff, vid, img)
+		if err != nil {
+			return err
+		}
+		cuts = append(cuts, cut)
+		if keep && cut > 0 {
+			manifest.Segments[i].Cut = cut.Seconds()
+		}
 		b, err := os.ReadFile(img)
 		if err != nil {
 			return fmt.Errorf("vidai: couldn't read image: %w", err)
@@ -86,7 +249,7 @@ func Run(ctx context.Context, cfg *Config) error {
 		name := filepath.Base(img)
 
 		// Generate video
-		imageURL, assetID, err := client.Upload(ctx, name, b)
+		imageURL, assetID, err := client.Upload(ctx, name, bytes.NewReader(b))
 		if err != nil {
 			return fmt.Errorf("vidai: couldn't upload image: %w", err)
 		}
@@ -98,81 +261,226 @@ func Run(ctx context.Context, cfg *Config) error {
 				log.Println(fmt.Errorf("vidai: couldn't delete asset: %w", err))
 			}
 		}()
-		gen, err := client.Generate(ctx, &runway.GenerateRequest{
-			Model:       cfg.Model,
+		step := prompts.At(steps, i).WithDefaults(cfg.Model, cfg.Seconds)
+		req := &runway.GenerateRequest{
+			Model:       step.Model,
 			AssetURL:    imageURL,
-			Prompt:      "",
+			Prompt:      step.Prompt,
 			Interpolate: cfg.Interpolate,
 			Upscale:     cfg.Upscale,
 			Watermark:   cfg.Watermark,
 			Extend:      false,
 			ExploreMode: cfg.Explore,
-			Seconds:     cfg.Seconds,
-		})
-		if err != nil {
-			return fmt.Errorf("vidai: couldn't generate video: %w", err)
+			Seconds:     step.Seconds,
 		}
-		urls = append(urls, gen.URL)
 
-		// Remove temporary image
-		if err := os.Remove(img); err != nil {
-			log.Println(fmt.Errorf("vidai: couldn't remove image: %w", err))
-		}
+		var gen *runway.Generation
+		if cfg.Candidates > 1 {
+			// Generate several takes and continue from the chosen one
+			takes, err := generateTakes(ctx, client, req, cfg.Candidates, takesDir, fmt.Sprintf("%s-%d", base, i+1))
+			if err != nil {
+				return err
+			}
+			j, err := pick.pick(ctx, i, takes)
+			if err != nil {
+				return err
+			}
+			log.Printf("vidai: using take %s for step %d\n", takes[j].path, i+1)
+			gen = takes[j].gen
+			vid = takes[j].path
+		} else {
+			gen, err = client.Generate(ctx, req)
+			if err != nil {
+				return fmt.Errorf("vidai: couldn't generate video: %w", err)
+			}
 
-		// Download video to temp file
-		vid = filepath.Join(os.TempDir(), fmt.Sprintf("%s-%d.mp4", base, i+1))
-		if err := client.Download(ctx, gen.URL, vid); err != nil {
-			return fmt.Errorf("vidai: couldn't download video: %w", err)
+			// Download video
+			vid = filepath.Join(dir, fmt.Sprintf("%s-%d.mp4", base, i+1))
+			if err := client.Download(ctx, gen.URL, vid); err != nil {
+				return fmt.Errorf("vidai: couldn't download video: %w", err)
+			}
 		}
+		urls = append(urls, gen.URL)
 		videos = append(videos, vid)
+		ev.AddTask(gen.TaskID, gen.URL)
+
+		// Update the manifest after each step so that it is available even
+		// if the process is interrupted
+		if keep {
+			if err := addSegment(ctx, ff, dir, manifest, Segment{
+				Path:        vid,
+				SourceFrame: relPath(dir, img),
+				SeedFrame:   seed.String(),
+				Prompt:      step.Prompt,
+				Model:       step.Model,
+				TaskID:      gen.TaskID,
+				Seed:        gen.Seed,
+				URL:         gen.URL,
+			}); err != nil {
+				return err
+			}
+		}
 	}
 
 	if cfg.Output != "" {
-		// Create list of videos
-		var listData string
-		for _, v := range videos {
-			listData += fmt.Sprintf("file '%s'\n", filepath.Base(v))
-		}
-		list := filepath.Join(os.TempDir(), fmt.Sprintf("%s-list.txt", base))
-		if err := os.WriteFile(list, []byte(listData), 0644); err != nil {
-			return fmt.Errorf("vidai: couldn't create list file: %w", err)
+		if err := join(ctx, ff, cfg, videos, cuts, ws.Path(base)); err != nil {
+			return err
 		}
+	}
 
-		// Combine videos using the following command:
-		// ffmpeg -f concat -safe 0 -i list.txt -c copy output.mp4
-		cmd := exec.CommandContext(ctx, "ffmpeg", "-f", "concat", "-safe", "0", "-i", list, "-c", "copy", "-y", cfg.Output)
-		cmdOut, err := cmd.CombinedOutput()
-		if err != nil {
-			return fmt.Errorf("vidai: couldn't combine videos (%s): %w", string(cmdOut), err)
+	fmt.Println("URLs:")
+	for _, u := range urls {
+		fmt.Println(u)
+	}
+	return nil
+}
+
+// rebuild joins the segments of a manifest into the output.
+func rebuild(ctx context.Context, cfg *Config) error {
+	if cfg.Output == "" {
+		return fmt.Errorf("output is required")
+	}
+	m, videos, err := readManifest(cfg.Manifest)
+	if err != nil {
+		return err
+	}
+	ws, err := workspace.New(cfg.WorkDir, "extend", cfg.KeepTemp)
+	if err != nil {
+		return err
+	}
+	defer ws.Close()
+	ff := ffmpeg.New(&ffmpeg.Config{
+		Bin:     cfg.FFmpeg,
+		Debug:   cfg.Debug,
+		TempDir: ws.Dir(),
+	})
+	var cuts []time.Duration
+	for _, s := range m.Segments {
+		cuts = append(cuts, time.Duration(s.Cut*float64(time.Second)))
+	}
+	return join(ctx, ff, cfg, videos, cuts, ws.Path(inputBase(m.Input)))
+}
+
+// join removes the duplicated seam frames, matches colors and combines the
+// videos into the output, exporting it to the output format if needed.
+// Videos are cut at the given positions, see seams.
+// Temporary files are created with the tmpBase prefix.
+func join(ctx context.Context, ff *ffmpeg.FFmpeg, cfg *Config, videos []string, cuts []time.Duration, tmpBase string) error {
+	segments, err := seams(ctx, ff, videos, cuts, cfg.TrimFrames, cfg.ColorMatch, tmpBase)
+	if err != nil {
+		return err
+	}
+	export := ffmpeg.NeedsExport(cfg.Format)
+	output := cfg.Output
+	if export || cfg.Audio != "" {
+		output = tmpBase + "-joined.mp4"
+	}
+	if err := ff.Join(ctx, segments, output, &ffmpeg.JoinOptions{
+		Normalize: cfg.Normalize,
+		Crossfade: cfg.Crossfade,
+	}); err != nil {
+		return fmt.Errorf("vidai: couldn't combine videos: %w", err)
+	}
+
+	// Add audio track
+	if cfg.Audio != "" {
+		withAudio := cfg.Output
+		if export {
+			withAudio = tmpBase + "-audio.mp4"
+		}
+		if err := ff.AddAudio(ctx, output, cfg.Audio, withAudio, &ffmpeg.AudioOptions{
+			FadeIn:  cfg.AudioFade,
+			FadeOut: cfg.AudioFade,
+			NoLoop:  cfg.AudioNoLoop,
+		}); err != nil {
+			return fmt.Errorf("vidai: couldn't add audio: %w", err)
 		}
+		output = withAudio
+	}
+
+	if !export {
+		return nil
+	}
+	if err := ff.Export(ctx, output, cfg.Output, &ffmpeg.ExportOptions{
+		Format: cfg.Format,
+	}); err != nil {
+		return fmt.Errorf("vidai: couldn't export video: %w", err)
+	}
+	return nil
+}
 
-		// Remove temporary list file
-		if err := os.Remove(list); err != nil {
-			log.Println(fmt.Errorf("vidai: couldn't remove list file: %w", err))
+// coverSteps returns the number of extensions needed to cover the audio
+// duration. Each step adds its configured seconds minus the frames trimmed
+// at the seam and the crossfade overlap.
+func coverSteps(ctx context.Context, ff *ffmpeg.FFmpeg, video string, audio time.Duration, steps []prompts.Step, cfg *Config) (int, error) {
+	info, err := ff.Probe(ctx, video)
+	if err != nil {
+		return 0, err
+	}
+	// Generated videos are expected to have the frame rate of the input
+	fps := info.FPS
+	if fps <= 0 {
+		fps = 24
+	}
+	seam := time.Duration(float64(cfg.TrimFrames)/fps*float64(time.Second)) + cfg.Crossfade
+	total := info.Duration
+	n := 0
+	for total < audio {
+		step := prompts.At(steps, n).WithDefaults(cfg.Model, cfg.Seconds)
+		added := time.Duration(step.Seconds)*time.Second - seam
+		if added <= 0 {
+			return 0, fmt.Errorf("vidai: seconds must be greater than the trimmed frames and crossfade to cover the audio")
 		}
+		total += added
+		n++
 	}
+	return n, nil
+}
 
-	// Remove temporary videos
-	for _, v := range videos {
-		if err := os.Remove(v); err != nil {
-			log.Println(fmt.Errorf("vidai: couldn't remove video: %w", err))
+// joinedDuration returns the duration of the videos once they are joined,
+// see join.
+func joinedDuration(ctx context.Context, ff *ffmpeg.FFmpeg, videos []string, cuts []time.Duration, cfg *Config) (time.Duration, error) {
+	var total time.Duration
+	for i, v := range videos {
+		info, err := ff.Probe(ctx, v)
+		if err != nil {
+			return 0, err
+		}
+		d := info.Duration
+		if i < len(cuts) && cuts[i] > 0 {
+			d = cuts[i]
 		}
+		if i > 0 {
+			if info.FPS > 0 {
+				d -= time.Duration(float64(cfg.TrimFrames) / info.FPS * float64(time.Second))
+			}
+			d -= cfg.Crossfade
+		}
+		total += d
 	}
+	return total, nil
+}
 
-	fmt.Println("URLs:")
-	for _, u := range urls {
-		fmt.Println(u)
+// addSegment adds a segment to the manifest and writes it to dir.
+func addSegment(ctx context.Context, ff *ffmpeg.FFmpeg, dir string, m *Manifest, s Segment) error {
+	info, err := ff.Probe(ctx, s.Path)
+	if err != nil {
+		return fmt.Errorf("vidai: couldn't probe segment: %w", err)
 	}
-	return nil
+	s.Index = len(m.Segments)
+	s.Duration = info.Duration.Seconds()
+	s.Path = relPath(dir, s.Path)
+	m.Segments = append(m.Segments, s)
+	return writeManifest(dir, m)
 }
 
-func copyFile(src, dst string) error {
-	// Open source file
-	srcFile, err := os.Open(src)
+// copyInput copies the input video to a local file.
+func copyInput(ctx context.Context, client *runway.Client, src, dst string) error {
+	_, in, err := input.Open(ctx, client, src)
 	if err != nil {
-		return fmt.Errorf("vidai: couldn't open source file: %w", err)
+		return fmt.Errorf("vidai: couldn't open input: %w", err)
 	}
-	defer srcFile.Close()
+	defer in.Close()
 
 	// Create destination file
 	dstFile, err := os.Create(dst)
@@ -182,8 +490,32 @@ func copyFile(src, dst string) error {
 	defer dstFile.Close()
 
 	// Copy source to destination
-	if _, err := io.Copy(dstFile, srcFile); err != nil {
+	if _, err := io.Copy(dstFile, in); err != nil {
 		return fmt.Errorf("vidai: couldn't copy source to destination: %w", err)
 	}
 	return nil
 }
+
+// inputBase returns a base name for temporary files based on the input.
+func inputBase(src string) string {
+	if id, ok := input.Asset(src); ok {
+		return id
+	}
+	if src == "-" {
+		return "stdin"
+	}
+	if u, err := url.Parse(src); err == nil && u.Scheme != "" {
+		src = u.Path
+	}
+	base := strings.TrimSuffix(filepath.Base(src), filepath.Ext(src))
+	if base == "" || base == "." || base == "/" {
+		return "input"
+	}
+	return base
+}
+
+func removeFile(name string) {
+	if err := os.Remove(name); err != nil && !os.IsNotExist(err) {
+		log.Println(fmt.Errorf("vidai: couldn't remove temp file: %w", err))
+	}
+}
@@ -0,0 +1,180 @@
+package extend
+
+import (
+	"context"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/igolaizola/vidai/pkg/ffmpeg"
+)
+
+func TestInputBase(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"video.mp4", "video"},
+		{"/tmp/clips/video.final.mp4", "video.final"},
+		{"-", "stdin"},
+		{"asset:1234-abcd", "1234-abcd"},
+		{"https://example.com/media/clip.mp4?token=abc", "clip"},
+		{"https://example.com/", "input"},
+		{"https://example.com", "input"},
+	}
+	for _, tt := range tests {
+		if got := inputBase(tt.src); got != tt.want {
+			t.Errorf("%q: got %q, want %q", tt.src, got, tt.want)
+		}
+	}
+}
+
+const probeJSON = `{
+	"streams": [{"codec_type": "video", "codec_name": "h264", "width": 1280, "height": 768, "avg_frame_rate": "25/1"}],
+	"format": {"duration": "4.000000"}
+}`
+
+func newFake() *ffmpeg.Fake {
+	return &ffmpeg.Fake{
+		Handler: func(name string, args []string, stdout io.Writer) error {
+			if strings.HasSuffix(name, "ffprobe") {
+				_, err := io.WriteString(stdout, probeJSON)
+				return err
+			}
+			return nil
+		},
+	}
+}
+
+func TestCutAfter(t *testing.T) {
+	info := &ffmpeg.Info{Duration: 4 * time.Second, FPS: 25}
+	tests := []struct {
+		at   time.Duration
+		want time.Duration
+	}{
+		{0, 40 * time.Millisecond},
+		{2 * time.Second, 2040 * time.Millisecond},
+		// The last frame doesn't need a cut
+		{3960 * time.Millisecond, 0},
+		{3950 * time.Millisecond, 0},
+	}
+	for _, tt := range tests {
+		if got := cutAfter(info, tt.at); got != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.at, got, tt.want)
+		}
+	}
+	if got := cutAfter(&ffmpeg.Info{Duration: 4 * time.Second}, time.Second); got != 0 {
+		t.Errorf("unknown fps: got %s, want 0", got)
+	}
+}
+
+func TestSeams(t *testing.T) {
+	fake := newFake()
+	ff := ffmpeg.New(&ffmpeg.Config{Runner: fake})
+	videos := []string{"in-0.mp4", "in-1.mp4", "in-2.mp4", "in-3.mp4"}
+	cuts := []time.Duration{3 * time.Second, 0, 2 * time.Second}
+	got, err := seams(context.Background(), ff, videos, cuts, 1, false, "tmp/in")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"tmp/in-0-seam.mp4", "tmp/in-1-seam.mp4", "tmp/in-2-seam.mp4", "tmp/in-3-seam.mp4"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	// The input is only cut, generated segments are trimmed and cut at the
+	// position relative to their untrimmed start
+	wantArgs := map[string]struct {
+		trim bool
+		t    string
+	}{
+		"in-0.mp4": {false, "3"},
+		"in-1.mp4": {true, ""},
+		"in-2.mp4": {true, "1.96"},
+		"in-3.mp4": {true, ""},
+	}
+	var n int
+	for _, c := range fake.Calls() {
+		if c.Name != "ffmpeg" {
+			continue
+		}
+		n++
+		in, vf, dur := argValue(c.Args, "-i"), argValue(c.Args, "-vf"), argValue(c.Args, "-t")
+		w, ok := wantArgs[in]
+		if !ok {
+			t.Fatalf("unexpected input %s", in)
+		}
+		if trim := strings.Contains(vf, "trim=start_frame=1"); trim != w.trim {
+			t.Errorf("%s: unexpected filters %q", in, vf)
+		}
+		if dur != w.t {
+			t.Errorf("%s: got duration %q, want %q", in, dur, w.t)
+		}
+	}
+	if n != len(videos) {
+		t.Errorf("got %d transcodes, want %d", n, len(videos))
+	}
+
+	// Nothing to do without cuts, trims or color matching
+	got, err = seams(context.Background(), ff, videos, nil, 0, false, "tmp/in")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, videos) {
+		t.Errorf("got %v, want %v", got, videos)
+	}
+}
+
+func argValue(args []string, name string) string {
+	for i := 0; i < len(args)-1; i++ {
+		if args[i] == name {
+			return args[i+1]
+		}
+	}
+	return ""
+}
+
+func TestCoverSteps(t *testing.T) {
+	ff := ffmpeg.New(&ffmpeg.Config{Runner: newFake()})
+	ctx := context.Background()
+	cfg := &Config{Model: "gen3", Seconds: 5, TrimFrames: 1, Crossfade: 500 * time.Millisecond}
+
+	// Each step adds 5s minus 0.04s trimmed and 0.5s of crossfade
+	tests := []struct {
+		audio time.Duration
+		want  int
+	}{
+		{3 * time.Second, 0},
+		{8 * time.Second, 1},
+		{13 * time.Second, 3},
+	}
+	for _, tt := range tests {
+		got, err := coverSteps(ctx, ff, "in.mp4", tt.audio, nil, cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: got %d steps, want %d", tt.audio, got, tt.want)
+		}
+	}
+	cfg.Crossfade = 5 * time.Second
+	if _, err := coverSteps(ctx, ff, "in.mp4", 13*time.Second, nil, cfg); err == nil {
+		t.Error("expected error for steps that don't add any duration")
+	}
+}
+
+func TestJoinedDuration(t *testing.T) {
+	ff := ffmpeg.New(&ffmpeg.Config{Runner: newFake()})
+	cfg := &Config{TrimFrames: 1, Crossfade: 500 * time.Millisecond}
+	videos := []string{"in-0.mp4", "in-1.mp4", "in-2.mp4"}
+	cuts := []time.Duration{3 * time.Second, 0}
+	got, err := joinedDuration(context.Background(), ff, videos, cuts, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := 9920 * time.Millisecond; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
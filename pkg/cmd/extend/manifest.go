@@ -0,0 +1,100 @@
+package extend
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// ManifestName is the name of the manifest file written to the segments dir.
+const ManifestName = "manifest.json"
+
+// Manifest describes the segments of an extended video so that it can be
+// rebuilt without generating them again.
+type Manifest struct {
+	Input    string    `json:"input"`
+	Segments []Segment `json:"segments"`
+}
+
+// Segment is a single video of an extended video. The first segment is the
+// input video and it has no generation data.
+type Segment struct {
+	Index int `json:"index"`
+	// Path is relative to the manifest dir unless it is absolute.
+	Path     string  `json:"path"`
+	Duration float64 `json:"duration"`
+	// Cut is the position in seconds where the segment is cut when joining
+	// because a frame before its end was the seed of the next segment.
+	Cut float64 `json:"cut,omitempty"`
+	// SourceFrame is the path to the frame used to generate the segment and
+	// SeedFrame the mode used to select it.
+	SourceFrame string `json:"sourceFrame,omitempty"`
+	SeedFrame   string `json:"seedFrame,omitempty"`
+	Prompt      string `json:"prompt,omitempty"`
+	Model       string `json:"model,omitempty"`
+	TaskID      string `json:"taskId,omitempty"`
+	Seed        int    `json:"seed,omitempty"`
+	URL         string `json:"url,omitempty"`
+}
+
+// writeManifest writes the manifest to the given dir.
+func writeManifest(dir string, m *Manifest) error {
+	js, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("vidai: couldn't marshal manifest: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, ManifestName), js, 0o644); err != nil {
+		return fmt.Errorf("vidai: couldn't write manifest: %w", err)
+	}
+	return nil
+}
+
+// readManifest reads a manifest and returns the paths of its segments.
+// The manifest can be given as the file or as its dir.
+func readManifest(name string) (*Manifest, []string, error) {
+	if fi, err := os.Stat(name); err == nil && fi.IsDir() {
+		name = filepath.Join(name, ManifestName)
+	}
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return nil, nil, fmt.Errorf("vidai: couldn't read manifest: %w", err)
+	}
+	var m Manifest
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, nil, fmt.Errorf("vidai: couldn't parse manifest: %w", err)
+	}
+	if len(m.Segments) == 0 {
+		return nil, nil, fmt.Errorf("vidai: manifest %s has no segments", name)
+	}
+	dir := filepath.Dir(name)
+	var paths []string
+	for _, s := range m.Segments {
+		p := s.Path
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(dir, p)
+		}
+		if _, err := os.Stat(p); err != nil {
+			return nil, nil, fmt.Errorf("vidai: segment %d: %w", s.Index, err)
+		}
+		paths = append(paths, p)
+	}
+	return &m, paths, nil
+}
+
+// relPath returns the path relative to dir if possible.
+func relPath(dir, p string) string {
+	abs, err := filepath.Abs(p)
+	if err != nil {
+		return p
+	}
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return abs
+	}
+	rel, err := filepath.Rel(absDir, abs)
+	if err != nil {
+		return abs
+	}
+	return rel
+}
@@ -0,0 +1,79 @@
+package extend
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestManifest(t *testing.T) {
+	dir := t.TempDir()
+	segments := filepath.Join(dir, "segments")
+	if err := os.MkdirAll(segments, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	// The input is outside the segments dir and uses a relative path
+	input := filepath.Join(dir, "car.mp4")
+	files := []string{input, filepath.Join(segments, "car-1.mp4"), filepath.Join(segments, "car-2.mp4")}
+	for _, f := range files {
+		if err := os.WriteFile(f, []byte("video"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := &Manifest{
+		Input: "car.mp4",
+		Segments: []Segment{
+			{Index: 0, Path: relPath(segments, input), Duration: 4, Cut: 3.5},
+			{Index: 1, Path: relPath(segments, files[1]), Duration: 5, SourceFrame: "car-0.jpg", SeedFrame: "offset=-500ms", Prompt: "a car", Model: "gen3", TaskID: "task-1", Seed: 42, URL: "https://example.com/1.mp4"},
+			{Index: 2, Path: files[2], Duration: 5, SourceFrame: "car-1.jpg", SeedFrame: "last", Model: "gen2"},
+		},
+	}
+	if got := want.Segments[0].Path; got != filepath.Join("..", "car.mp4") {
+		t.Fatalf("got relative path %q", got)
+	}
+	if got := want.Segments[1].Path; got != "car-1.mp4" {
+		t.Fatalf("got relative path %q", got)
+	}
+	if err := writeManifest(segments, want); err != nil {
+		t.Fatal(err)
+	}
+
+	// The manifest can be read from another working dir using the file or
+	// its dir
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+	for _, name := range []string{segments, filepath.Join(segments, ManifestName)} {
+		got, paths, err := readManifest(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %+v, want %+v", name, got, want)
+		}
+		if !reflect.DeepEqual(paths, files) {
+			t.Errorf("%s: got paths %v, want %v", name, paths, files)
+		}
+	}
+
+	// Missing segments are reported
+	if err := os.Remove(files[1]); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := readManifest(segments); err == nil {
+		t.Error("expected error for missing segment")
+	}
+	if err := writeManifest(segments, &Manifest{Input: "car.mp4"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := readManifest(segments); err == nil {
+		t.Error("expected error for empty manifest")
+	}
+}
@@ -0,0 +1,151 @@
+package extend
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/igolaizola/vidai/pkg/ffmpeg"
+)
+
+// seams prepares the generated segments to be joined without visible hitches.
+// Each generated segment starts with the frame used as its seed, which is
+// already the last frame of the previous segment, so the first frames are
+// trimmed. Segments whose seed frame isn't their last frame are cut after it
+// at the positions given by cuts, where zero keeps the whole segment.
+// Optionally, the colors of each segment are adjusted to match the end of
+// the previous one.
+// The first video is the original input and it is only modified if it must
+// be cut. Processed segments are written with the tmpBase prefix.
+func seams(ctx context.Context, ff *ffmpeg.FFmpeg, videos []string, cuts []time.Duration, trimFrames int, colorMatch bool, tmpBase string) ([]string, error) {
+	var segments []string
+	for i, raw := range videos {
+		var cut time.Duration
+		if i < len(cuts) {
+			cut = cuts[i]
+		}
+		trim := i > 0 && trimFrames > 0
+		var filters []string
+		if trim {
+			filters = append(filters, fmt.Sprintf("trim=start_frame=%d", trimFrames), "setpts=PTS-STARTPTS")
+		}
+		if i > 0 && colorMatch {
+			filter, err := colorFilter(ctx, ff, segments[i-1], raw, trimFrames, fmt.Sprintf("%s-%d", tmpBase, i))
+			if err != nil {
+				return nil, err
+			}
+			if filter != "" {
+				filters = append(filters, filter)
+			}
+		}
+		if len(filters) == 0 && cut <= 0 {
+			segments = append(segments, raw)
+			continue
+		}
+		// The cut position is relative to the untrimmed segment
+		if cut > 0 && trim {
+			info, err := ff.Probe(ctx, raw)
+			if err != nil {
+				return nil, err
+			}
+			if info.FPS > 0 {
+				cut -= time.Duration(float64(trimFrames) / info.FPS * float64(time.Second))
+			}
+			if cut <= 0 {
+				return nil, fmt.Errorf("vidai: segment %d is empty after trimming its seam frames", i)
+			}
+		}
+		out := fmt.Sprintf("%s-%d-seam.mp4", tmpBase, i)
+		if err := ff.Transcode(ctx, raw, out, &ffmpeg.TranscodeOptions{
+			Filters:  filters,
+			Duration: cut,
+		}); err != nil {
+			return nil, fmt.Errorf("vidai: couldn't process segment %d: %w", i, err)
+		}
+		segments = append(segments, out)
+	}
+	return segments, nil
+}
+
+// colorFilter returns a filter that scales the color channels of the next
+// segment so that its first frame matches the mean color of the last frame
+// of the previous segment.
+func colorFilter(ctx context.Context, ff *ffmpeg.FFmpeg, prev, next string, trimFrames int, tmpBase string) (string, error) {
+	prevFrame := tmpBase + "-prev.png"
+	nextFrame := tmpBase + "-next.png"
+	defer removeFile(prevFrame)
+	defer removeFile(nextFrame)
+
+	if err := ff.ExtractLastFrame(ctx, prev, prevFrame); err != nil {
+		return "", fmt.Errorf("vidai: couldn't extract last frame: %w", err)
+	}
+	var at time.Duration
+	if trimFrames > 0 {
+		info, err := ff.Probe(ctx, next)
+		if err != nil {
+			return "", err
+		}
+		if info.FPS > 0 {
+			at = time.Duration(float64(trimFrames) / info.FPS * float64(time.Second))
+		}
+	}
+	if err := ff.ExtractFrame(ctx, next, nextFrame, at); err != nil {
+		return "", fmt.Errorf("vidai: couldn't extract first frame: %w", err)
+	}
+	p, err := meanColor(prevFrame)
+	if err != nil {
+		return "", err
+	}
+	n, err := meanColor(nextFrame)
+	if err != nil {
+		return "", err
+	}
+	var gains [3]float64
+	for i := range gains {
+		gains[i] = colorGain(p[i], n[i])
+	}
+	if gains == [3]float64{1, 1, 1} {
+		return "", nil
+	}
+	return fmt.Sprintf("colorchannelmixer=rr=%.4f:gg=%.4f:bb=%.4f", gains[0], gains[1], gains[2]), nil
+}
+
+// colorGain returns the gain to convert a channel mean into the target mean.
+// Gains are limited to avoid color casts on very dark or very bright frames.
+func colorGain(target, mean float64) float64 {
+	if mean < 1 || target < 1 {
+		return 1
+	}
+	g := target / mean
+	switch {
+	case g < 0.8:
+		g = 0.8
+	case g > 1.25:
+		g = 1.25
+	}
+	return g
+}
+
+// meanColor returns the mean red, green and blue values of an image.
+func meanColor(name string) ([3]float64, error) {
+	img, err := readImage(name)
+	if err != nil {
+		return [3]float64{}, err
+	}
+	var sum [3]float64
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			r, g, bl, _ := img.At(x, y).RGBA()
+			sum[0] += float64(r >> 8)
+			sum[1] += float64(g >> 8)
+			sum[2] += float64(bl >> 8)
+		}
+	}
+	n := float64(b.Dx() * b.Dy())
+	if n == 0 {
+		return [3]float64{}, fmt.Errorf("vidai: empty frame %s", filepath.Base(name))
+	}
+	return [3]float64{sum[0] / n, sum[1] / n, sum[2] / n}, nil
+}
@@ -0,0 +1,184 @@
+package extend
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/jpeg"
+	_ "image/png"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/igolaizola/vidai/pkg/ffmpeg"
+	"github.com/igolaizola/vidai/pkg/preprocess"
+)
+
+// Seed frame modes.
+const (
+	seedLast     = "last"
+	seedSharpest = "sharpest"
+	seedOffset   = "offset="
+)
+
+// seedFrame selects the frame of a segment used to generate the next one.
+type seedFrame struct {
+	mode   string
+	offset time.Duration
+}
+
+// parseSeedFrame parses a seed frame mode: last, sharpest or offset=<duration>.
+// Offsets are relative to the end of the video if they are negative.
+func parseSeedFrame(v string) (*seedFrame, error) {
+	switch {
+	case v == "" || v == seedLast:
+		return &seedFrame{mode: seedLast}, nil
+	case v == seedSharpest:
+		return &seedFrame{mode: seedSharpest}, nil
+	case strings.HasPrefix(v, seedOffset):
+		d, err := time.ParseDuration(strings.TrimPrefix(v, seedOffset))
+		if err != nil {
+			return nil, fmt.Errorf("vidai: invalid seed frame offset %q: %w", v, err)
+		}
+		return &seedFrame{mode: seedOffset, offset: d}, nil
+	default:
+		return nil, fmt.Errorf("vidai: unknown seed frame %q (last, sharpest or offset=<duration>)", v)
+	}
+}
+
+func (s *seedFrame) String() string {
+	if s.mode == seedOffset {
+		return fmt.Sprintf("%s%s", seedOffset, s.offset)
+	}
+	return s.mode
+}
+
+// extract writes the seed frame of the video to the output as JPEG.
+// When the seed isn't the last frame, it returns the position where the
+// video must be cut so that the seed becomes its last frame, otherwise the
+// next segment would replay the frames after it. Zero keeps the whole video.
+func (s *seedFrame) extract(ctx context.Context, ff *ffmpeg.FFmpeg, video, output string) (time.Duration, error) {
+	switch s.mode {
+	case seedSharpest:
+		return extractSharpest(ctx, ff, video, output)
+	case seedOffset:
+		info, err := ff.Probe(ctx, video)
+		if err != nil {
+			return 0, err
+		}
+		at := s.offset
+		if at < 0 {
+			at += info.Duration
+		}
+		if at < 0 || at >= info.Duration {
+			return 0, fmt.Errorf("vidai: seed frame offset %s is out of %s", s.offset, filepath.Base(video))
+		}
+		if err := ff.ExtractFrame(ctx, video, output, at); err != nil {
+			return 0, fmt.Errorf("vidai: couldn't extract frame at %s: %w", s.offset, err)
+		}
+		log.Printf("vidai: using frame at %s as seed of %s\n", at, filepath.Base(video))
+		return cutAfter(info, at), nil
+	default:
+		if err := ff.ExtractLastFrame(ctx, video, output); err != nil {
+			return 0, fmt.Errorf("vidai: couldn't extract last frame: %w", err)
+		}
+		return 0, nil
+	}
+}
+
+// extractSharpest scores the frames of the last second of the video and
+// writes the sharpest one to the output. It returns the position where the
+// video must be cut after the frame or zero if it is the last one.
+func extractSharpest(ctx context.Context, ff *ffmpeg.FFmpeg, video, output string) (time.Duration, error) {
+	info, err := ff.Probe(ctx, video)
+	if err != nil {
+		return 0, err
+	}
+	dir, err := os.MkdirTemp(ff.TempDir(), "vidai-frames-*")
+	if err != nil {
+		return 0, fmt.Errorf("vidai: couldn't create frames dir: %w", err)
+	}
+	defer func() {
+		if err := os.RemoveAll(dir); err != nil {
+			log.Println(fmt.Errorf("vidai: couldn't remove frames dir: %w", err))
+		}
+	}()
+	if err := ff.ExtractFrames(ctx, video, filepath.Join(dir, "frame-%04d.png"), -time.Second); err != nil {
+		return 0, fmt.Errorf("vidai: couldn't extract frames: %w", err)
+	}
+	frames, err := filepath.Glob(filepath.Join(dir, "frame-*.png"))
+	if err != nil {
+		return 0, fmt.Errorf("vidai: couldn't list frames: %w", err)
+	}
+	if len(frames) == 0 {
+		return 0, fmt.Errorf("vidai: no frames extracted from %s", video)
+	}
+	sort.Strings(frames)
+
+	var best image.Image
+	bestIndex, bestScore := -1, 0.0
+	var lastScore float64
+	for i, frame := range frames {
+		img, err := readImage(frame)
+		if err != nil {
+			return 0, err
+		}
+		score := preprocess.Sharpness(img)
+		// Later frames win ties because they are closer to the end
+		if bestIndex < 0 || score >= bestScore {
+			best, bestIndex, bestScore = img, i, score
+		}
+		lastScore = score
+	}
+	log.Printf("vidai: using frame %d of the last %d as seed of %s (sharpness %.1f, last frame %.1f)\n",
+		len(frames)-bestIndex, len(frames), filepath.Base(video), bestScore, lastScore)
+
+	f, err := os.Create(output)
+	if err != nil {
+		return 0, fmt.Errorf("vidai: couldn't create seed frame: %w", err)
+	}
+	defer f.Close()
+	if err := jpeg.Encode(f, best, &jpeg.Options{Quality: 95}); err != nil {
+		return 0, fmt.Errorf("vidai: couldn't encode seed frame: %w", err)
+	}
+	back := len(frames) - 1 - bestIndex
+	if back == 0 || info.FPS <= 0 {
+		return 0, nil
+	}
+	at := info.Duration - time.Duration(float64(back+1)/info.FPS*float64(time.Second))
+	if at < 0 {
+		at = 0
+	}
+	return cutAfter(info, at), nil
+}
+
+// cutAfter returns the position after the frame at the given time, or zero
+// if it is the last frame of the video.
+func cutAfter(info *ffmpeg.Info, at time.Duration) time.Duration {
+	if info.FPS <= 0 {
+		return 0
+	}
+	end := at + time.Duration(float64(time.Second)/info.FPS)
+	// Allow a margin of half a frame for rounding errors
+	if end+time.Duration(float64(time.Second)/info.FPS/2) >= info.Duration {
+		return 0
+	}
+	return end
+}
+
+// readImage decodes an image file.
+func readImage(name string) (image.Image, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("vidai: couldn't open frame: %w", err)
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("vidai: couldn't decode frame %s: %w", filepath.Base(name), err)
+	}
+	return img, nil
+}
@@ -0,0 +1,112 @@
+package extend
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/igolaizola/vidai/pkg/ffmpeg"
+)
+
+func TestParseSeedFrame(t *testing.T) {
+	tests := []struct {
+		v       string
+		want    string
+		wantErr bool
+	}{
+		{v: "", want: "last"},
+		{v: "last", want: "last"},
+		{v: "sharpest", want: "sharpest"},
+		{v: "offset=-0.5s", want: "offset=-500ms"},
+		{v: "offset=1s", want: "offset=1s"},
+		{v: "offset=abc", wantErr: true},
+		{v: "first", wantErr: true},
+	}
+	for _, tt := range tests {
+		s, err := parseSeedFrame(tt.v)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%q: expected error", tt.v)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", tt.v, err)
+			continue
+		}
+		if got := s.String(); got != tt.want {
+			t.Errorf("%q: got %q, want %q", tt.v, got, tt.want)
+		}
+	}
+}
+
+func TestExtractSharpest(t *testing.T) {
+	tmp := t.TempDir()
+	var framesDir string
+	fake := &ffmpeg.Fake{
+		Handler: func(name string, args []string, stdout io.Writer) error {
+			if strings.HasSuffix(name, "ffprobe") {
+				_, err := io.WriteString(stdout, probeJSON)
+				return err
+			}
+			// The second of three frames is the sharpest
+			pattern := args[len(args)-1]
+			framesDir = filepath.Dir(pattern)
+			for i := 1; i <= 3; i++ {
+				if err := writeFrame(fmt.Sprintf(pattern, i), i == 2); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+	ff := ffmpeg.New(&ffmpeg.Config{Runner: fake, TempDir: tmp})
+	output := filepath.Join(tmp, "seed.jpg")
+	cut, err := extractSharpest(context.Background(), ff, "in.mp4", output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The frame is one before the last of a 4s video at 25 fps
+	if want := 3960 * time.Millisecond; cut != want {
+		t.Errorf("got cut %s, want %s", cut, want)
+	}
+	if filepath.Dir(framesDir) != tmp {
+		t.Errorf("frames dir %s isn't in the temp dir %s", framesDir, tmp)
+	}
+	if _, err := os.Stat(framesDir); !os.IsNotExist(err) {
+		t.Errorf("frames dir wasn't removed: %v", err)
+	}
+	if _, err := os.Stat(output); err != nil {
+		t.Error(err)
+	}
+}
+
+// writeFrame writes a flat frame or a sharp checkerboard.
+func writeFrame(name string, sharp bool) error {
+	img := image.NewGray(image.Rect(0, 0, 16, 16))
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 16; x++ {
+			c := uint8(128)
+			if sharp && (x+y)%2 == 0 {
+				c = 255
+			} else if sharp {
+				c = 0
+			}
+			img.SetGray(x, y, color.Gray{Y: c})
+		}
+	}
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return png.Encode(f, img)
+}
@@ -0,0 +1,290 @@
+package extend
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/igolaizola/vidai/pkg/ffmpeg"
+	"github.com/igolaizola/vidai/pkg/preprocess"
+	"github.com/igolaizola/vidai/pkg/runway"
+)
+
+// take is a candidate generation of an extension step.
+type take struct {
+	// index is the 1-based number of the take, it doesn't change if other
+	// takes of the same step fail.
+	index int
+	gen   *runway.Generation
+	path  string
+}
+
+// generateTakes runs n generations of the same request concurrently and
+// downloads them to dir. Failed generations are logged and skipped, an error
+// is only returned if none of them succeeds.
+func generateTakes(ctx context.Context, client *runway.Client, req *runway.GenerateRequest, n int, dir, prefix string) ([]take, error) {
+	takes := make([]*take, n)
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for j := 0; j < n; j++ {
+		wg.Add(1)
+		go func(j int) {
+			defer wg.Done()
+			r := *req
+			gen, err := client.Generate(ctx, &r)
+			if err != nil {
+				errs[j] = fmt.Errorf("vidai: couldn't generate take %d: %w", j+1, err)
+				return
+			}
+			path := filepath.Join(dir, fmt.Sprintf("%s-take-%d.mp4", prefix, j+1))
+			if err := client.Download(ctx, gen.URL, path); err != nil {
+				errs[j] = fmt.Errorf("vidai: couldn't download take %d: %w", j+1, err)
+				return
+			}
+			takes[j] = &take{index: j + 1, gen: gen, path: path}
+		}(j)
+	}
+	wg.Wait()
+
+	var ok []take
+	for j, t := range takes {
+		if t == nil {
+			log.Println(errs[j])
+			continue
+		}
+		ok = append(ok, *t)
+	}
+	if len(ok) == 0 {
+		return nil, errs[0]
+	}
+	return ok, nil
+}
+
+// picker chooses one of the takes of an extension step.
+type picker interface {
+	pick(ctx context.Context, step int, takes []take) (int, error)
+}
+
+// Scorer returns a score for a candidate video, higher is better.
+type Scorer func(ctx context.Context, ff *ffmpeg.FFmpeg, video string) (float64, error)
+
+// Scorers available to pick takes automatically by name.
+var Scorers = map[string]Scorer{
+	"sharpness": sharpnessScore,
+	"motion":    motionScore,
+}
+
+const (
+	pickInteractive = "interactive"
+	pickFile        = "file="
+)
+
+// newPicker returns the picker for the given mode: interactive, the name of
+// a scorer or file=<path> with the 1-based take to pick for each step, one
+// per line. The interactive picker reads stdin, so it can't be used if the
+// input is read from stdin too.
+func newPicker(mode string, ff *ffmpeg.FFmpeg, stdinInput bool) (picker, error) {
+	switch {
+	case mode == "" || mode == pickInteractive:
+		if stdinInput {
+			return nil, fmt.Errorf("vidai: interactive pick can't be used with stdin input, use another pick mode")
+		}
+		return &interactivePicker{in: bufio.NewReader(os.Stdin), out: os.Stdout}, nil
+	case strings.HasPrefix(mode, pickFile):
+		return newFilePicker(strings.TrimPrefix(mode, pickFile))
+	}
+	scorer, ok := Scorers[mode]
+	if !ok {
+		return nil, fmt.Errorf("vidai: unknown pick mode %q", mode)
+	}
+	return &scorePicker{name: mode, scorer: scorer, ff: ff}, nil
+}
+
+// interactivePicker prints the takes and asks the user to choose one.
+type interactivePicker struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+func (p *interactivePicker) pick(ctx context.Context, step int, takes []take) (int, error) {
+	fmt.Fprintf(p.out, "Takes for step %d:\n", step+1)
+	var choices []string
+	for _, t := range takes {
+		preview := t.gen.URL
+		if len(t.gen.PreviewURLs) > 0 {
+			preview = t.gen.PreviewURLs[0]
+		}
+		fmt.Fprintf(p.out, "  %d) %s\n     %s\n", t.index, t.path, preview)
+		choices = append(choices, strconv.Itoa(t.index))
+	}
+	for {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		fmt.Fprintf(p.out, "Choose a take [%s]: ", strings.Join(choices, ", "))
+		line, err := p.in.ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			return 0, fmt.Errorf("vidai: couldn't read choice: %w", err)
+		}
+		if n, convErr := strconv.Atoi(strings.TrimSpace(line)); convErr == nil {
+			if j := takeIndex(takes, n); j >= 0 {
+				return j, nil
+			}
+		}
+		if err == io.EOF {
+			return 0, fmt.Errorf("vidai: invalid choice %q", strings.TrimSpace(line))
+		}
+	}
+}
+
+// filePicker picks the takes listed in a file.
+type filePicker struct {
+	picks []int
+}
+
+func newFilePicker(name string) (*filePicker, error) {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return nil, fmt.Errorf("vidai: couldn't read pick file: %w", err)
+	}
+	var picks []int
+	for _, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		n, err := strconv.Atoi(line)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("vidai: invalid pick %q", line)
+		}
+		picks = append(picks, n)
+	}
+	return &filePicker{picks: picks}, nil
+}
+
+func (p *filePicker) pick(_ context.Context, step int, takes []take) (int, error) {
+	if step >= len(p.picks) {
+		return 0, fmt.Errorf("vidai: no pick for step %d", step+1)
+	}
+	n := p.picks[step]
+	j := takeIndex(takes, n)
+	if j < 0 {
+		return 0, fmt.Errorf("vidai: picked take %d of step %d isn't available", n, step+1)
+	}
+	return j, nil
+}
+
+// takeIndex returns the position of the take with the given number or -1 if
+// it isn't available.
+func takeIndex(takes []take, n int) int {
+	for j, t := range takes {
+		if t.index == n {
+			return j
+		}
+	}
+	return -1
+}
+
+// scorePicker picks the take with the highest score.
+type scorePicker struct {
+	name   string
+	scorer Scorer
+	ff     *ffmpeg.FFmpeg
+}
+
+func (p *scorePicker) pick(ctx context.Context, step int, takes []take) (int, error) {
+	best, bestScore := 0, math.Inf(-1)
+	for j, t := range takes {
+		score, err := p.scorer(ctx, p.ff, t.path)
+		if err != nil {
+			return 0, fmt.Errorf("vidai: couldn't score take %d: %w", t.index, err)
+		}
+		log.Printf("vidai: step %d take %d %s score %.2f\n", step+1, t.index, p.name, score)
+		if score > bestScore {
+			best, bestScore = j, score
+		}
+	}
+	return best, nil
+}
+
+// sharpnessScore scores a video by the sharpness of its last frame, which is
+// the one used to generate the next step.
+func sharpnessScore(ctx context.Context, ff *ffmpeg.FFmpeg, video string) (float64, error) {
+	frames, err := scoreFrames(ctx, ff, video, false)
+	if err != nil {
+		return 0, err
+	}
+	return preprocess.Sharpness(frames[0]), nil
+}
+
+// motionScore scores a video by the mean luminance difference between its
+// first and last frames, so that takes that barely move score lower.
+func motionScore(ctx context.Context, ff *ffmpeg.FFmpeg, video string) (float64, error) {
+	frames, err := scoreFrames(ctx, ff, video, true)
+	if err != nil {
+		return 0, err
+	}
+	first, last := frames[0], frames[1]
+	b := last.Bounds()
+	if first.Bounds().Dx() != b.Dx() || first.Bounds().Dy() != b.Dy() {
+		return 0, fmt.Errorf("vidai: frames of %s have different sizes", video)
+	}
+	fb := first.Bounds()
+	var sum float64
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			sum += math.Abs(luminance(first.At(fb.Min.X+x, fb.Min.Y+y)) - luminance(last.At(b.Min.X+x, b.Min.Y+y)))
+		}
+	}
+	return sum / float64(b.Dx()*b.Dy()), nil
+}
+
+// scoreFrames returns the last frame of the video, preceded by the first one
+// if requested.
+func scoreFrames(ctx context.Context, ff *ffmpeg.FFmpeg, video string, withFirst bool) ([]image.Image, error) {
+	dir, err := os.MkdirTemp(ff.TempDir(), "vidai-score-*")
+	if err != nil {
+		return nil, fmt.Errorf("vidai: couldn't create score dir: %w", err)
+	}
+	defer func() {
+		if err := os.RemoveAll(dir); err != nil {
+			log.Println(fmt.Errorf("vidai: couldn't remove score dir: %w", err))
+		}
+	}()
+	var frames []image.Image
+	if withFirst {
+		first := filepath.Join(dir, "first.png")
+		if err := ff.ExtractFrame(ctx, video, first, 0); err != nil {
+			return nil, fmt.Errorf("vidai: couldn't extract first frame: %w", err)
+		}
+		img, err := readImage(first)
+		if err != nil {
+			return nil, err
+		}
+		frames = append(frames, img)
+	}
+	last := filepath.Join(dir, "last.png")
+	if err := ff.ExtractLastFrame(ctx, video, last); err != nil {
+		return nil, fmt.Errorf("vidai: couldn't extract last frame: %w", err)
+	}
+	img, err := readImage(last)
+	if err != nil {
+		return nil, err
+	}
+	return append(frames, img), nil
+}
+
+func luminance(c color.Color) float64 {
+	r, g, b, _ := c.RGBA()
+	return (0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)) / 257
+}
@@ -0,0 +1,146 @@
+package extend
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/igolaizola/vidai/pkg/ffmpeg"
+	"github.com/igolaizola/vidai/pkg/runway"
+)
+
+// testTakes returns the takes left after the second one failed.
+func testTakes() []take {
+	return []take{
+		{index: 1, gen: &runway.Generation{URL: "https://example.com/1.mp4"}, path: "step-take-1.mp4"},
+		{index: 3, gen: &runway.Generation{URL: "https://example.com/3.mp4"}, path: "step-take-3.mp4"},
+	}
+}
+
+func TestNewPicker(t *testing.T) {
+	ff := ffmpeg.New(&ffmpeg.Config{Runner: &ffmpeg.Fake{}})
+	file := filepath.Join(t.TempDir(), "picks.txt")
+	if err := os.WriteFile(file, []byte("1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		mode    string
+		stdin   bool
+		want    string
+		wantErr bool
+	}{
+		{mode: "", want: "*extend.interactivePicker"},
+		{mode: "interactive", want: "*extend.interactivePicker"},
+		{mode: "", stdin: true, wantErr: true},
+		{mode: "interactive", stdin: true, wantErr: true},
+		{mode: "sharpness", stdin: true, want: "*extend.scorePicker"},
+		{mode: "motion", want: "*extend.scorePicker"},
+		{mode: "file=" + file, stdin: true, want: "*extend.filePicker"},
+		{mode: "file=" + file + ".missing", wantErr: true},
+		{mode: "random", wantErr: true},
+	}
+	for _, tt := range tests {
+		p, err := newPicker(tt.mode, ff, tt.stdin)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%q (stdin %v): expected error", tt.mode, tt.stdin)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", tt.mode, err)
+			continue
+		}
+		if got := fmt.Sprintf("%T", p); got != tt.want {
+			t.Errorf("%q: got %s, want %s", tt.mode, got, tt.want)
+		}
+	}
+}
+
+func TestFilePicker(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "picks.txt")
+	if err := os.WriteFile(file, []byte("# picks\n3\n\n 2 \n1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	p, err := newFilePicker(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	// Take numbers are kept when a take fails
+	j, err := p.pick(ctx, 0, testTakes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := testTakes()[j].index; got != 3 {
+		t.Errorf("step 1: got take %d, want 3", got)
+	}
+	if _, err := p.pick(ctx, 1, testTakes()); err == nil {
+		t.Error("step 2: expected error for failed take")
+	}
+	if j, err := p.pick(ctx, 2, testTakes()); err != nil || j != 0 {
+		t.Errorf("step 3: got (%d, %v), want (0, nil)", j, err)
+	}
+	if _, err := p.pick(ctx, 3, testTakes()); err == nil {
+		t.Error("step 4: expected error for missing pick")
+	}
+
+	for _, content := range []string{"1\ntwo\n", "0\n", "-1\n"} {
+		if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := newFilePicker(file); err == nil {
+			t.Errorf("%q: expected error", content)
+		}
+	}
+}
+
+func TestInteractivePicker(t *testing.T) {
+	ctx := context.Background()
+	var out strings.Builder
+	p := &interactivePicker{in: bufio.NewReader(strings.NewReader("2\nx\n3\n")), out: &out}
+	j, err := p.pick(ctx, 0, testTakes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := testTakes()[j].index; got != 3 {
+		t.Errorf("got take %d, want 3", got)
+	}
+	if s := out.String(); !strings.Contains(s, "3) step-take-3.mp4") || !strings.Contains(s, "[1, 3]") {
+		t.Errorf("unexpected output %q", s)
+	}
+
+	// A choice without a new line is accepted at the end of the input
+	p = &interactivePicker{in: bufio.NewReader(strings.NewReader("1")), out: io.Discard}
+	if j, err := p.pick(ctx, 0, testTakes()); err != nil || j != 0 {
+		t.Errorf("got (%d, %v), want (0, nil)", j, err)
+	}
+	p = &interactivePicker{in: bufio.NewReader(strings.NewReader("2")), out: io.Discard}
+	if _, err := p.pick(ctx, 0, testTakes()); err == nil {
+		t.Error("expected error for failed take")
+	}
+}
+
+func TestScorePicker(t *testing.T) {
+	scores := map[string]float64{"step-take-1.mp4": 0.5, "step-take-3.mp4": 0.8}
+	p := &scorePicker{
+		name: "test",
+		scorer: func(_ context.Context, _ *ffmpeg.FFmpeg, video string) (float64, error) {
+			return scores[video], nil
+		},
+	}
+	j, err := p.pick(context.Background(), 0, testTakes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := testTakes()[j].index; got != 3 {
+		t.Errorf("got take %d, want 3", got)
+	}
+}
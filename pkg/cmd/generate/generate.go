@@ -1,6 +1,7 @@
 package generate
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -10,6 +11,12 @@ import (
 	"strings"
 	"time"
 
+	"github.com/igolaizola/vidai/pkg/cache"
+	"github.com/igolaizola/vidai/pkg/ffmpeg"
+	"github.com/igolaizola/vidai/pkg/input"
+	"github.com/igolaizola/vidai/pkg/notify"
+	"github.com/igolaizola/vidai/pkg/preprocess"
+	"github.com/igolaizola/vidai/pkg/prompts"
 	"github.com/igolaizola/vidai/pkg/runway"
 )
 
@@ -23,6 +30,7 @@ type Config struct {
 	Model       string
 	Folder      string
 	Image       string
+	Video       string
 	Text        string
 	Extend      int
 	Interpolate bool
@@ -34,49 +42,162 @@ type Config struct {
 	Explore     bool
 	LastFrame   bool
 	Seconds     int
+	Prompts     string
+	PromptFile  string
+
+	Fit          string
+	NoPreprocess bool
+	FFmpeg       string
+	Format       string
+	Post         string
+	Audio        string
+	AudioFade    time.Duration
+
+	KeepUploads    bool
+	UploadCache    string
+	UploadCacheTTL time.Duration
+
+	NotifyURL    string
+	NotifySecret string
+	OnComplete   string
+	// Event collects the tasks of the run when it is part of another
+	// command (optional).
+	Event *notify.Event
 }
 
 // Run generates a video from an image and a text prompt.
 func Run(ctx context.Context, cfg *Config) error {
-	if cfg.Image == "" && cfg.Text == "" {
-		return fmt.Errorf("vidai: image or text is required")
+	n, err := notify.New(&notify.Config{
+		URL:     cfg.NotifyURL,
+		Secret:  cfg.NotifySecret,
+		Command: cfg.OnComplete,
+		Debug:   cfg.Debug,
+	})
+	if err != nil {
+		return err
+	}
+	ev := &notify.Event{Command: "generate", Output: cfg.Output}
+	err = run(ctx, cfg, ev)
+	if cfg.Event != nil {
+		cfg.Event.Merge(ev)
+	}
+	n.Done(ctx, ev, err)
+	return err
+}
+
+func run(ctx context.Context, cfg *Config, ev *notify.Event) error {
+	if cfg.Image == "" && cfg.Video == "" && cfg.Text == "" {
+		return fmt.Errorf("vidai: image, video or text is required")
+	}
+	if cfg.Image != "" && cfg.Video != "" {
+		return fmt.Errorf("vidai: image and video can't be used together")
 	}
 	if cfg.Token == "" {
 		return fmt.Errorf("token is required")
 	}
+	var post []ffmpeg.Op
+	if cfg.Post != "" {
+		var err error
+		post, err = ffmpeg.ParseOps(cfg.Post)
+		if err != nil {
+			return fmt.Errorf("vidai: couldn't parse post ops: %w", err)
+		}
+	}
+	steps, err := prompts.Load(cfg.Prompts, cfg.PromptFile)
+	if err != nil {
+		return fmt.Errorf("vidai: couldn't load prompts: %w", err)
+	}
+	// Each prompt is an extension step
+	extend := cfg.Extend
+	if len(steps) > extend {
+		extend = len(steps)
+	}
+
+	// Uploads are only cached if they are kept after the generation
+	var uploadCache runway.UploadCache
+	if cfg.KeepUploads {
+		c, err := cache.Open(cfg.UploadCache, cfg.UploadCacheTTL)
+		if err != nil {
+			return fmt.Errorf("vidai: couldn't open upload cache: %w", err)
+		}
+		uploadCache = c
+	}
 	client, err := runway.New(&runway.Config{
-		Token:  cfg.Token,
-		Wait:   cfg.Wait,
-		Debug:  cfg.Debug,
-		Proxy:  cfg.Proxy,
-		Folder: cfg.Folder,
+		Token:       cfg.Token,
+		Wait:        cfg.Wait,
+		Debug:       cfg.Debug,
+		Proxy:       cfg.Proxy,
+		Folder:      cfg.Folder,
+		UploadCache: uploadCache,
 	})
 	if err != nil {
 		return fmt.Errorf("vidai: couldn't create client: %w", err)
 	}
 
+	ff := ffmpeg.New(&ffmpeg.Config{
+		Bin:   cfg.FFmpeg,
+		Debug: cfg.Debug,
+	})
+
 	var imageURL string
 	var fileName string
-	if cfg.Image != "" {
-		b, err := os.ReadFile(cfg.Image)
+	switch {
+	case cfg.Image != "" && isAsset(cfg.Image):
+		fileName, imageURL, err = assetURL(ctx, client, cfg.Image)
+		if err != nil {
+			return err
+		}
+	case cfg.Image != "":
+		var b []byte
+		fileName, b, err = input.ReadAll(ctx, client, cfg.Image)
 		if err != nil {
 			return fmt.Errorf("vidai: couldn't read image: %w", err)
 		}
-		fileName = filepath.Base(cfg.Image)
+
+		// Convert the image to a supported format and size
+		if !cfg.NoPreprocess {
+			var ext string
+			b, ext, err = preprocess.Process(b, &preprocess.Config{
+				Width:    cfg.Width,
+				Height:   cfg.Height,
+				Portrait: cfg.Portrait,
+				Fit:      cfg.Fit,
+			})
+			if err != nil {
+				return fmt.Errorf("vidai: couldn't preprocess image: %w", err)
+			}
+			fileName = fmt.Sprintf("%s.%s", strings.TrimSuffix(fileName, filepath.Ext(fileName)), ext)
+		}
 
 		var assetID string
-		imageURL, assetID, err = client.Upload(ctx, fileName, b)
+		imageURL, assetID, err = client.Upload(ctx, fileName, bytes.NewReader(b))
 		if err != nil {
 			return fmt.Errorf("vidai: couldn't upload image: %w", err)
 		}
-		defer func() {
-			// Delete asset
-			deleteCTX, cancel := context.WithTimeout(context.Background(), 15*time.Second)
-			defer cancel()
-			if err := client.Delete(deleteCTX, assetID); err != nil {
-				log.Println(fmt.Errorf("vidai: couldn't delete asset: %w", err))
-			}
-		}()
+		if !cfg.KeepUploads {
+			defer deleteAsset(client, assetID)
+		}
+	case cfg.Video != "" && isAsset(cfg.Video):
+		fileName, imageURL, err = assetURL(ctx, client, cfg.Video)
+		if err != nil {
+			return err
+		}
+	case cfg.Video != "":
+		video, cleanup, err := input.ToFile(ctx, client, cfg.Video, os.TempDir())
+		if err != nil {
+			return fmt.Errorf("vidai: couldn't read video: %w", err)
+		}
+		defer cleanup()
+		fileName = filepath.Base(video)
+
+		var assetID string
+		imageURL, assetID, err = uploadVideo(ctx, client, ff, video)
+		if err != nil {
+			return err
+		}
+		if !cfg.KeepUploads {
+			defer deleteAsset(client, assetID)
+		}
 	}
 	gen, err := client.Generate(ctx, &runway.GenerateRequest{
 		Model:       cfg.Model,
@@ -86,7 +207,7 @@ func Run(ctx context.Context, cfg *Config) error {
 		Interpolate: cfg.Interpolate,
 		Upscale:     cfg.Upscale,
 		Watermark:   cfg.Watermark,
-		Extend:      false,
+		Extend:      cfg.Video != "",
 		Width:       cfg.Width,
 		Height:      cfg.Height,
 		Portrait:    cfg.Portrait,
@@ -97,29 +218,43 @@ func Run(ctx context.Context, cfg *Config) error {
 	if err != nil {
 		return fmt.Errorf("vidai: couldn't generate video: %w", err)
 	}
+	ev.AddTask(gen.TaskID, gen.URL)
 
 	// Extend video
-	for i := 0; i < cfg.Extend; i++ {
+	for i := 0; i < extend; i++ {
+		step := prompts.At(steps, i).WithDefaults(cfg.Model, cfg.Seconds)
 		gen, err = client.Generate(ctx, &runway.GenerateRequest{
-			Model:       cfg.Model,
+			Model:       step.Model,
 			AssetURL:    gen.URL,
-			Prompt:      "",
+			Prompt:      step.Prompt,
 			Interpolate: cfg.Interpolate,
 			Upscale:     cfg.Upscale,
 			Watermark:   cfg.Watermark,
 			Extend:      true,
-			Seconds:     cfg.Seconds,
+			Seconds:     step.Seconds,
 		})
 		if err != nil {
 			return fmt.Errorf("vidai: couldn't extend video: %w", err)
 		}
+		ev.AddTask(gen.TaskID, gen.URL)
 	}
 
-	// Use temp file if no output is set and we need to extend the video
+	// Download the video to a temp file if no output is set and the video
+	// was extended, or if it must be exported
 	videoPath := cfg.Output
-	if videoPath == "" && cfg.Extend > 0 {
-		base := strings.TrimSuffix(filepath.Base(cfg.Image), filepath.Ext(cfg.Image))
-		videoPath = filepath.Join(os.TempDir(), fmt.Sprintf("%s.mp4", base))
+	export := cfg.Output != "" && ffmpeg.NeedsExport(cfg.Format)
+	if (videoPath == "" && extend > 0) || export {
+		f, err := os.CreateTemp("", "vidai-*.mp4")
+		if err != nil {
+			return fmt.Errorf("vidai: couldn't create temp file: %w", err)
+		}
+		_ = f.Close()
+		videoPath = f.Name()
+		defer func() {
+			if err := os.Remove(videoPath); err != nil && !os.IsNotExist(err) {
+				log.Println(fmt.Errorf("vidai: couldn't remove temp file: %w", err))
+			}
+		}()
 	}
 
 	// Download video
@@ -129,6 +264,42 @@ func Run(ctx context.Context, cfg *Config) error {
 		}
 	}
 
+	// Post-process video
+	if videoPath != "" && len(post) > 0 {
+		if err := replaceVideo(videoPath, func(out string) error {
+			if err := ff.Edit(ctx, videoPath, out, post); err != nil {
+				return fmt.Errorf("vidai: couldn't post-process video: %w", err)
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+
+	// Add audio track
+	if videoPath != "" && cfg.Audio != "" {
+		if err := replaceVideo(videoPath, func(out string) error {
+			if err := ff.AddAudio(ctx, videoPath, cfg.Audio, out, &ffmpeg.AudioOptions{
+				FadeIn:  cfg.AudioFade,
+				FadeOut: cfg.AudioFade,
+			}); err != nil {
+				return fmt.Errorf("vidai: couldn't add audio: %w", err)
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+
+	// Export video
+	if export {
+		if err := ff.Export(ctx, videoPath, cfg.Output, &ffmpeg.ExportOptions{
+			Format: cfg.Format,
+		}); err != nil {
+			return fmt.Errorf("vidai: couldn't export video: %w", err)
+		}
+	}
+
 	js, err := json.MarshalIndent(gen, "", "  ")
 	if err != nil {
 		return fmt.Errorf("vidai: couldn't marshal json: %w", err)
@@ -136,3 +307,85 @@ func Run(ctx context.Context, cfg *Config) error {
 	fmt.Println(string(js))
 	return nil
 }
+
+// uploadVideo uploads a local video using its first frame as preview.
+func uploadVideo(ctx context.Context, client *runway.Client, ff *ffmpeg.FFmpeg, video string) (string, string, error) {
+	base := strings.TrimSuffix(filepath.Base(video), filepath.Ext(video))
+	name := fmt.Sprintf("%s-preview.jpg", base)
+
+	// Extract first frame from video to a unique file, so that concurrent
+	// runs with videos of the same name don't share it
+	f, err := os.CreateTemp(ff.TempDir(), "vidai-preview-*.jpg")
+	if err != nil {
+		return "", "", fmt.Errorf("vidai: couldn't create preview file: %w", err)
+	}
+	_ = f.Close()
+	preview := f.Name()
+	defer func() {
+		if err := os.Remove(preview); err != nil && !os.IsNotExist(err) {
+			log.Println(fmt.Errorf("vidai: couldn't remove preview: %w", err))
+		}
+	}()
+	if err := ff.ExtractFrame(ctx, video, preview, 0); err != nil {
+		return "", "", fmt.Errorf("vidai: couldn't extract preview frame: %w", err)
+	}
+
+	v, err := os.Open(video)
+	if err != nil {
+		return "", "", fmt.Errorf("vidai: couldn't open video: %w", err)
+	}
+	defer v.Close()
+	p, err := os.Open(preview)
+	if err != nil {
+		return "", "", fmt.Errorf("vidai: couldn't open preview: %w", err)
+	}
+	defer p.Close()
+
+	videoURL, assetID, err := client.UploadVideo(ctx, filepath.Base(video), v, name, p)
+	if err != nil {
+		return "", "", fmt.Errorf("vidai: couldn't upload video: %w", err)
+	}
+	return videoURL, assetID, nil
+}
+
+// replaceVideo replaces the video with the output of fn.
+func replaceVideo(video string, fn func(output string) error) error {
+	tmp, err := os.CreateTemp(filepath.Dir(video), "vidai-*.mp4")
+	if err != nil {
+		return fmt.Errorf("vidai: couldn't create temp file: %w", err)
+	}
+	_ = tmp.Close()
+	if err := fn(tmp.Name()); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), video); err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("vidai: couldn't replace video: %w", err)
+	}
+	return nil
+}
+
+func deleteAsset(client *runway.Client, assetID string) {
+	deleteCTX, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	if err := client.Delete(deleteCTX, assetID); err != nil {
+		log.Println(fmt.Errorf("vidai: couldn't delete asset: %w", err))
+	}
+}
+
+func isAsset(src string) bool {
+	_, ok := input.Asset(src)
+	return ok
+}
+
+// assetURL returns the name and URL of a runway asset so that it can be used
+// without uploading it again.
+func assetURL(ctx context.Context, client *runway.Client, src string) (string, string, error) {
+	id, _ := input.Asset(src)
+	_, u, _, err := client.GetAsset(ctx, id)
+	if err != nil {
+		return "", "", fmt.Errorf("vidai: couldn't get asset: %w", err)
+	}
+	return id, u, nil
+}
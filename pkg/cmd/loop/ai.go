@@ -0,0 +1,139 @@
+package loop
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/igolaizola/vidai/pkg/ffmpeg"
+	"github.com/igolaizola/vidai/pkg/input"
+	"github.com/igolaizola/vidai/pkg/notify"
+	"github.com/igolaizola/vidai/pkg/preprocess"
+	"github.com/igolaizola/vidai/pkg/runway"
+	"github.com/igolaizola/vidai/pkg/workspace"
+)
+
+// aiLoop generates a bridge that goes back to the first frame of the input
+// and joins it after the input.
+// The first frame is used as the end keyframe of the bridge, so the loop
+// point matches exactly and the start of the bridge is blended with the end
+// of the input using a crossfade.
+// If the input is an image, a clip starting with the image is generated
+// first. The generated tasks are recorded in the event.
+func aiLoop(ctx context.Context, cfg *Config, ff *ffmpeg.FFmpeg, ws *workspace.Workspace, ev *notify.Event, output string, audio bool) error {
+	if cfg.Token == "" {
+		return fmt.Errorf("token is required")
+	}
+	switch cfg.Model {
+	case "gen3", "gen3-turbo":
+	default:
+		return fmt.Errorf("vidai: ai loops require a gen3 model, got %q", cfg.Model)
+	}
+	if cfg.Crossfade <= 0 {
+		return fmt.Errorf("vidai: ai loops require a crossfade to join the bridge")
+	}
+	client, err := runway.New(&runway.Config{
+		Token:  cfg.Token,
+		Wait:   cfg.Wait,
+		Debug:  cfg.Debug,
+		Proxy:  cfg.Proxy,
+		Folder: cfg.Folder,
+	})
+	if err != nil {
+		return fmt.Errorf("vidai: couldn't create client: %w", err)
+	}
+
+	clip, cleanup, err := input.ToFile(ctx, client, cfg.Input, ws.Dir())
+	if err != nil {
+		return fmt.Errorf("vidai: couldn't read input: %w", err)
+	}
+	defer cleanup()
+	b, err := os.ReadFile(clip)
+	if err != nil {
+		return fmt.Errorf("vidai: couldn't read input: %w", err)
+	}
+
+	// Get the first frame of the loop
+	var first []byte
+	var firstName string
+	_, sniffErr := preprocess.Sniff(b)
+	isImage := sniffErr == nil
+	if isImage {
+		var ext string
+		first, ext, err = preprocess.Process(b, &preprocess.Config{})
+		if err != nil {
+			return fmt.Errorf("vidai: couldn't preprocess image: %w", err)
+		}
+		firstName = "first." + ext
+	} else {
+		frame := ws.Path("first.jpg")
+		if err := ff.ExtractFrame(ctx, clip, frame, 0); err != nil {
+			return fmt.Errorf("vidai: couldn't extract first frame: %w", err)
+		}
+		first, err = os.ReadFile(frame)
+		if err != nil {
+			return fmt.Errorf("vidai: couldn't read first frame: %w", err)
+		}
+		firstName = filepath.Base(frame)
+	}
+
+	firstURL, assetID, err := client.Upload(ctx, firstName, bytes.NewReader(first))
+	if err != nil {
+		return fmt.Errorf("vidai: couldn't upload first frame: %w", err)
+	}
+	defer func() {
+		deleteCTX, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+		defer cancel()
+		if err := client.Delete(deleteCTX, assetID); err != nil {
+			log.Println(fmt.Errorf("vidai: couldn't delete asset: %w", err))
+		}
+	}()
+	req := &runway.GenerateRequest{
+		Model:       cfg.Model,
+		AssetURL:    firstURL,
+		AssetName:   firstName,
+		Prompt:      cfg.Text,
+		Interpolate: true,
+		ExploreMode: cfg.Explore,
+		Seconds:     cfg.Seconds,
+	}
+
+	// Generate a clip starting with the image
+	if isImage {
+		clip = ws.Path("clip.mp4")
+		if err := generate(ctx, client, ev, req, clip); err != nil {
+			return fmt.Errorf("vidai: couldn't generate clip: %w", err)
+		}
+	}
+
+	// Generate a bridge that ends with the first frame
+	bridgeReq := *req
+	bridgeReq.LastFrame = true
+	bridge := ws.Path("bridge.mp4")
+	if err := generate(ctx, client, ev, &bridgeReq, bridge); err != nil {
+		return fmt.Errorf("vidai: couldn't generate bridge: %w", err)
+	}
+
+	if err := ff.BridgeLoop(ctx, clip, bridge, output, cfg.Crossfade, audio); err != nil {
+		return fmt.Errorf("vidai: couldn't join bridge: %w", err)
+	}
+	return nil
+}
+
+// generate generates a video, records its task in the event and downloads
+// the video to the output.
+func generate(ctx context.Context, client *runway.Client, ev *notify.Event, req *runway.GenerateRequest, output string) error {
+	gen, err := client.Generate(ctx, req)
+	if err != nil {
+		return err
+	}
+	ev.AddTask(gen.TaskID, gen.URL)
+	if err := client.Download(ctx, gen.URL, output); err != nil {
+		return fmt.Errorf("vidai: couldn't download video: %w", err)
+	}
+	return nil
+}
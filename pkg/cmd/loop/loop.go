@@ -3,40 +3,196 @@ package loop
 import (
 	"context"
 	"fmt"
-	"os"
-	"os/exec"
-	"path/filepath"
+	"math"
+	"time"
+
+	"github.com/igolaizola/vidai/pkg/ffmpeg"
+	"github.com/igolaizola/vidai/pkg/notify"
+	"github.com/igolaizola/vidai/pkg/workspace"
+)
+
+// Loop modes.
+const (
+	ModePingPong  = "pingpong"
+	ModeCrossfade = "crossfade"
+	ModeRepeat    = "repeat"
 )
 
-func Run(ctx context.Context, input, output string) error {
-	// Reverse video using the following command:
-	// ffmpeg -i input.mp4 -vf reverse temp.mp4
-	tmp := filepath.Join(os.TempDir(), fmt.Sprintf("%s-reversed.mp4", filepath.Base(input)))
-	cmd := exec.CommandContext(ctx, "ffmpeg", "-i", input, "-vf", "reverse", tmp)
-	cmdOut, err := cmd.CombinedOutput()
+// Audio modes.
+const (
+	AudioDrop    = "drop"
+	AudioForward = "forward"
+)
+
+type Config struct {
+	Token  string
+	Wait   time.Duration
+	Debug  bool
+	Proxy  string
+	Folder string
+
+	Input     string
+	Output    string
+	FFmpeg    string
+	Normalize bool
+	Mode      string
+	Crossfade time.Duration
+	Repeat    int
+	Duration  time.Duration
+	AudioMode string
+	WorkDir   string
+	KeepTemp  bool
+	Format    string
+	Audio     string
+	AudioFade time.Duration
+
+	// AI generates a bridge that ends on the first frame instead of using a
+	// mode
+	AI      bool
+	Model   string
+	Text    string
+	Seconds int
+	Explore bool
+
+	NotifyURL    string
+	NotifySecret string
+	OnComplete   string
+}
+
+// Run converts a video to a loop.
+// In pingpong mode the video is followed by its reversed version, in
+// crossfade mode its end fades into its beginning and in repeat mode it is
+// played as is. In AI mode a bridge that ends on the first frame is
+// generated instead. The loop is then repeated to reach the target duration.
+func Run(ctx context.Context, cfg *Config) error {
+	n, err := notify.New(&notify.Config{
+		URL:     cfg.NotifyURL,
+		Secret:  cfg.NotifySecret,
+		Command: cfg.OnComplete,
+		Debug:   cfg.Debug,
+	})
 	if err != nil {
-		return fmt.Errorf("vidai: couldn't reverse video (%s): %w", string(cmdOut), err)
+		return err
 	}
+	ev := &notify.Event{Command: "loop", Output: cfg.Output}
+	err = run(ctx, cfg, ev)
+	n.Done(ctx, ev, err)
+	return err
+}
 
-	// Obtain absolute path to input video
-	absInput, err := filepath.Abs(input)
+func run(ctx context.Context, cfg *Config, ev *notify.Event) error {
+	if cfg.Input == "" {
+		return fmt.Errorf("input is required")
+	}
+	if cfg.Output == "" {
+		return fmt.Errorf("output is required")
+	}
+	var audio bool
+	switch cfg.AudioMode {
+	case AudioDrop, "":
+	case AudioForward:
+		audio = true
+	default:
+		return fmt.Errorf("vidai: unknown audio mode %q", cfg.AudioMode)
+	}
+	repeat := cfg.Repeat
+	if repeat < 1 {
+		repeat = 1
+	}
+	mode := cfg.Mode
+	if mode == "" {
+		mode = ModePingPong
+	}
+	if !cfg.AI && mode == ModeRepeat && repeat == 1 && cfg.Duration == 0 {
+		return fmt.Errorf("vidai: repeat mode requires repeat or duration")
+	}
+
+	// Temporary files are removed with the workspace
+	ws, err := workspace.New(cfg.WorkDir, "loop", cfg.KeepTemp)
 	if err != nil {
-		return fmt.Errorf("vidai: couldn't get absolute path to input video: %w", err)
+		return err
 	}
+	defer ws.Close()
 
-	// Generate list of videos
-	listData := fmt.Sprintf("file '%s'\nfile '%s'\n", absInput, filepath.Base(tmp))
-	list := filepath.Join(os.TempDir(), fmt.Sprintf("%s-list.txt", filepath.Base(input)))
-	if err := os.WriteFile(list, []byte(listData), 0644); err != nil {
-		return fmt.Errorf("vidai: couldn't create list file: %w", err)
+	ff := ffmpeg.New(&ffmpeg.Config{
+		Bin:     cfg.FFmpeg,
+		Debug:   cfg.Debug,
+		TempDir: ws.Dir(),
+	})
+
+	// Create a single loop of the video
+	unit := ws.Path("loop.mp4")
+	switch {
+	case cfg.AI:
+		if err := aiLoop(ctx, cfg, ff, ws, ev, unit, audio); err != nil {
+			return err
+		}
+	case mode == ModePingPong:
+		if err := ff.PingPong(ctx, cfg.Input, unit, audio); err != nil {
+			return fmt.Errorf("vidai: couldn't create loop: %w", err)
+		}
+	case mode == ModeCrossfade:
+		if err := ff.CrossfadeLoop(ctx, cfg.Input, unit, cfg.Crossfade, audio); err != nil {
+			return fmt.Errorf("vidai: couldn't create loop: %w", err)
+		}
+	case mode == ModeRepeat && cfg.Normalize:
+		// The loop is repeated without encoding it again, so the input is
+		// encoded first to repeat a clean stream
+		if err := ff.Transcode(ctx, cfg.Input, unit, &ffmpeg.TranscodeOptions{
+			NoAudio: !audio,
+		}); err != nil {
+			return fmt.Errorf("vidai: couldn't normalize input: %w", err)
+		}
+	case mode == ModeRepeat:
+		unit = cfg.Input
+	default:
+		return fmt.Errorf("vidai: unknown loop mode %q", mode)
 	}
 
-	// Combine videos using the following command:
-	// ffmpeg -f concat -safe 0 -i list.txt -c copy output.mp4
-	cmd = exec.CommandContext(ctx, "ffmpeg", "-f", "concat", "-safe", "0", "-i", list, "-c", "copy", "-y", output)
-	cmdOut, err = cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("vidai: couldn't combine videos (%s): %w", string(cmdOut), err)
+	// Repeat the loop until the target duration is reached
+	if cfg.Duration > 0 {
+		info, err := ff.Probe(ctx, unit)
+		if err != nil {
+			return err
+		}
+		if info.Duration <= 0 {
+			return fmt.Errorf("vidai: couldn't get loop duration")
+		}
+		repeat = int(math.Ceil(cfg.Duration.Seconds() / info.Duration.Seconds()))
+	}
+	export := ffmpeg.NeedsExport(cfg.Format)
+	output := cfg.Output
+	if export || cfg.Audio != "" {
+		output = ws.Path("repeated.mp4")
+	}
+	// The loop audio is replaced if an audio track is set
+	if err := ff.Repeat(ctx, unit, output, repeat, cfg.Duration, audio && cfg.Audio == ""); err != nil {
+		return fmt.Errorf("vidai: couldn't repeat loop: %w", err)
+	}
+
+	// Add audio track
+	if cfg.Audio != "" {
+		withAudio := cfg.Output
+		if export {
+			withAudio = ws.Path("audio.mp4")
+		}
+		if err := ff.AddAudio(ctx, output, cfg.Audio, withAudio, &ffmpeg.AudioOptions{
+			FadeIn:  cfg.AudioFade,
+			FadeOut: cfg.AudioFade,
+		}); err != nil {
+			return fmt.Errorf("vidai: couldn't add audio: %w", err)
+		}
+		output = withAudio
+	}
+
+	// Export loop
+	if !export {
+		return nil
+	}
+	if err := ff.Export(ctx, output, cfg.Output, &ffmpeg.ExportOptions{
+		Format: cfg.Format,
+	}); err != nil {
+		return fmt.Errorf("vidai: couldn't export video: %w", err)
 	}
 	return nil
 }
@@ -0,0 +1,65 @@
+package prune
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/igolaizola/vidai/pkg/cache"
+	"github.com/igolaizola/vidai/pkg/runway"
+)
+
+type Config struct {
+	Token string
+	Wait  time.Duration
+	Debug bool
+	Proxy string
+
+	UploadCache    string
+	UploadCacheTTL time.Duration
+	All            bool
+}
+
+// Run deletes the remote datasets of expired cached uploads and removes them
+// from the cache.
+func Run(ctx context.Context, cfg *Config) error {
+	if cfg.Token == "" {
+		return fmt.Errorf("token is required")
+	}
+	c, err := cache.Open(cfg.UploadCache, cfg.UploadCacheTTL)
+	if err != nil {
+		return fmt.Errorf("vidai: couldn't open upload cache: %w", err)
+	}
+	entries := c.Entries(!cfg.All)
+	if len(entries) == 0 {
+		fmt.Println("nothing to prune")
+		return nil
+	}
+
+	client, err := runway.New(&runway.Config{
+		Token: cfg.Token,
+		Wait:  cfg.Wait,
+		Debug: cfg.Debug,
+		Proxy: cfg.Proxy,
+	})
+	if err != nil {
+		return fmt.Errorf("vidai: couldn't create client: %w", err)
+	}
+	var pruned int
+	for k, e := range entries {
+		if err := client.Delete(ctx, e.DatasetID); err != nil {
+			if ctx.Err() != nil {
+				return err
+			}
+			log.Println(fmt.Errorf("vidai: couldn't delete asset: %w", err))
+			continue
+		}
+		if err := c.Delete(k); err != nil {
+			return fmt.Errorf("vidai: couldn't update upload cache: %w", err)
+		}
+		pruned++
+	}
+	fmt.Printf("pruned %d of %d cached uploads\n", pruned, len(entries))
+	return nil
+}
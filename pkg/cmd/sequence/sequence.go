@@ -0,0 +1,255 @@
+package sequence
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/igolaizola/vidai/pkg/cmd/extend"
+	"github.com/igolaizola/vidai/pkg/cmd/generate"
+	"github.com/igolaizola/vidai/pkg/ffmpeg"
+	"github.com/igolaizola/vidai/pkg/notify"
+	"github.com/igolaizola/vidai/pkg/storyboard"
+	"github.com/igolaizola/vidai/pkg/workspace"
+)
+
+type Config struct {
+	Token   string
+	Wait    time.Duration
+	Debug   bool
+	Proxy   string
+	Folder  string
+	Explore bool
+
+	Storyboard string
+	Output     string
+	Model      string
+	Seconds    int
+	FFmpeg     string
+	ShotsDir   string
+	Force      bool
+	WorkDir    string
+	KeepTemp   bool
+	Format     string
+	Audio      string
+	AudioFade  time.Duration
+
+	NotifyURL    string
+	NotifySecret string
+	OnComplete   string
+}
+
+// Run generates the shots of a storyboard and assembles them into a single
+// video. Shots are cached by their spec, so only new or changed shots are
+// generated again on re-runs.
+func Run(ctx context.Context, cfg *Config) error {
+	n, err := notify.New(&notify.Config{
+		URL:     cfg.NotifyURL,
+		Secret:  cfg.NotifySecret,
+		Command: cfg.OnComplete,
+		Debug:   cfg.Debug,
+	})
+	if err != nil {
+		return err
+	}
+	ev := &notify.Event{Command: "sequence", Output: cfg.Output}
+	err = run(ctx, cfg, ev)
+	n.Done(ctx, ev, err)
+	return err
+}
+
+func run(ctx context.Context, cfg *Config, ev *notify.Event) error {
+	if cfg.Storyboard == "" {
+		return fmt.Errorf("storyboard is required")
+	}
+	sb, err := storyboard.Load(cfg.Storyboard)
+	if err != nil {
+		return err
+	}
+	output := cfg.Output
+	if output == "" {
+		output = sb.Output
+	}
+	if output == "" {
+		return fmt.Errorf("output is required")
+	}
+	ev.Output = output
+	if cfg.Token == "" {
+		return fmt.Errorf("token is required")
+	}
+
+	shotsDir := cfg.ShotsDir
+	if shotsDir == "" {
+		shotsDir = strings.TrimSuffix(output, filepath.Ext(output)) + "-shots"
+	}
+	if err := os.MkdirAll(shotsDir, 0o755); err != nil {
+		return fmt.Errorf("vidai: couldn't create shots dir: %w", err)
+	}
+
+	// Temporary files are removed with the workspace
+	ws, err := workspace.New(cfg.WorkDir, "sequence", cfg.KeepTemp)
+	if err != nil {
+		return err
+	}
+	defer ws.Close()
+
+	ff := ffmpeg.New(&ffmpeg.Config{
+		Bin:     cfg.FFmpeg,
+		Debug:   cfg.Debug,
+		TempDir: ws.Dir(),
+	})
+
+	var videos []string
+	var transitions []ffmpeg.Transition
+	for i := range sb.Shots {
+		shot := &sb.Shots[i]
+		if shot.Model == "" {
+			shot.Model = cfg.Model
+		}
+		if shot.Seconds == 0 {
+			shot.Seconds = cfg.Seconds
+		}
+
+		// Reuse the shot if its spec hasn't changed
+		key, err := shot.Key()
+		if err != nil {
+			return err
+		}
+		video := filepath.Join(shotsDir, fmt.Sprintf("%s-%s.mp4", shot.ID, key[:12]))
+		if _, err := os.Stat(video); err == nil && !cfg.Force {
+			log.Printf("vidai: shot %s reused from %s\n", shot.ID, video)
+		} else {
+			log.Printf("vidai: generating shot %s\n", shot.ID)
+			if err := render(ctx, cfg, ws, shot, video, ev); err != nil {
+				return err
+			}
+		}
+
+		if shot.Title != "" {
+			titled := ws.Path(fmt.Sprintf("%s-title.mp4", shot.ID))
+			if err := ff.Title(ctx, video, titled, &ffmpeg.TitleOptions{
+				Text:     shot.Title,
+				Duration: shot.TitleDuration,
+			}); err != nil {
+				return fmt.Errorf("vidai: couldn't add title to shot %s: %w", shot.ID, err)
+			}
+			video = titled
+		}
+		videos = append(videos, video)
+		if i > 0 {
+			transitions = append(transitions, ffmpeg.Transition{
+				Name:     shot.Transition,
+				Duration: shot.TransitionDuration,
+			})
+		}
+	}
+
+	export := ffmpeg.NeedsExport(cfg.Format)
+	joined := output
+	if export || cfg.Audio != "" {
+		joined = ws.Path("sequence.mp4")
+	}
+	if err := ff.Join(ctx, videos, joined, &ffmpeg.JoinOptions{
+		Transitions: transitions,
+	}); err != nil {
+		return fmt.Errorf("vidai: couldn't join shots: %w", err)
+	}
+
+	// Add audio track
+	if cfg.Audio != "" {
+		withAudio := output
+		if export {
+			withAudio = ws.Path("audio.mp4")
+		}
+		if err := ff.AddAudio(ctx, joined, cfg.Audio, withAudio, &ffmpeg.AudioOptions{
+			FadeIn:  cfg.AudioFade,
+			FadeOut: cfg.AudioFade,
+		}); err != nil {
+			return fmt.Errorf("vidai: couldn't add audio: %w", err)
+		}
+		joined = withAudio
+	}
+
+	// Export sequence
+	if export {
+		if err := ff.Export(ctx, joined, output, &ffmpeg.ExportOptions{
+			Format: cfg.Format,
+		}); err != nil {
+			return fmt.Errorf("vidai: couldn't export video: %w", err)
+		}
+	}
+	log.Printf("vidai: sequence saved to %s\n", output)
+	return nil
+}
+
+// render generates a shot and its extensions into the output and records
+// their tasks in the event.
+// The shot is written to a partial file first so that interrupted runs don't
+// leave a broken shot in the cache.
+func render(ctx context.Context, cfg *Config, ws *workspace.Workspace, shot *storyboard.Shot, output string, ev *notify.Event) error {
+	partial := strings.TrimSuffix(output, filepath.Ext(output)) + ".partial.mp4"
+	base := partial
+	// Each extension prompt is an extension step
+	extensions := shot.Extend > 0 || shot.Prompts != ""
+	if extensions {
+		base = ws.Path(fmt.Sprintf("%s-base.mp4", shot.ID))
+	}
+	if err := generate.Run(ctx, &generate.Config{
+		Token:       cfg.Token,
+		Wait:        cfg.Wait,
+		Debug:       cfg.Debug,
+		Proxy:       cfg.Proxy,
+		Output:      base,
+		Model:       shot.Model,
+		Folder:      cfg.Folder,
+		Image:       shot.Image,
+		Video:       shot.Video,
+		Text:        shot.Text,
+		Interpolate: true,
+		Explore:     cfg.Explore,
+		Seconds:     shot.Seconds,
+		FFmpeg:      cfg.FFmpeg,
+		Event:       ev,
+	}); err != nil {
+		return err
+	}
+
+	// Extensions are generated from the last frame of the shot
+	if extensions {
+		n := shot.Extend
+		if n < 1 {
+			n = 1
+		}
+		if err := extend.Run(ctx, &extend.Config{
+			Token:       cfg.Token,
+			Wait:        cfg.Wait,
+			Debug:       cfg.Debug,
+			Proxy:       cfg.Proxy,
+			Input:       base,
+			Output:      partial,
+			N:           n,
+			Prompts:     shot.Prompts,
+			Model:       shot.Model,
+			Folder:      cfg.Folder,
+			Interpolate: true,
+			Explore:     cfg.Explore,
+			Seconds:     shot.Seconds,
+			FFmpeg:      cfg.FFmpeg,
+			Normalize:   ffmpeg.NormalizeAuto,
+			TrimFrames:  1,
+			WorkDir:     ws.Dir(),
+			KeepTemp:    cfg.KeepTemp,
+			Event:       ev,
+		}); err != nil {
+			return err
+		}
+	}
+	if err := os.Rename(partial, output); err != nil {
+		return fmt.Errorf("vidai: couldn't save shot %s: %w", shot.ID, err)
+	}
+	return nil
+}
@@ -0,0 +1,244 @@
+package serve
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/igolaizola/vidai/pkg/cmd/extend"
+	"github.com/igolaizola/vidai/pkg/cmd/generate"
+	"github.com/igolaizola/vidai/pkg/cmd/loop"
+	"github.com/igolaizola/vidai/pkg/ffmpeg"
+)
+
+// Job types.
+const (
+	TypeGenerate = "generate"
+	TypeExtend   = "extend"
+	TypeLoop     = "loop"
+)
+
+// request is the body of a job submission.
+type request interface {
+	// format returns the output format of the job.
+	format() string
+	// run runs the job writing its result to the output.
+	run(ctx context.Context, cfg *Config, output string) error
+	// sources returns the fields with the media read by the job.
+	sources() []*string
+}
+
+// newRequest returns an empty request of the job type.
+func newRequest(typ string) (request, error) {
+	switch typ {
+	case TypeGenerate:
+		return &generateRequest{Model: "gen3", Seconds: 10, Interpolate: true}, nil
+	case TypeExtend:
+		return &extendRequest{N: 1, Model: "gen2", Seconds: 2, Interpolate: true, TrimFrames: 1}, nil
+	case TypeLoop:
+		return &loopRequest{Mode: loop.ModePingPong, Crossfade: duration(time.Second), Model: "gen3-turbo", Seconds: 5}, nil
+	default:
+		return nil, fmt.Errorf("unknown job type %q", typ)
+	}
+}
+
+// decodeRequest decodes and validates the body of a job submission.
+func decodeRequest(typ string, b []byte) (request, error) {
+	req, err := newRequest(typ)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(req); err != nil {
+		return nil, fmt.Errorf("invalid request: %w", err)
+	}
+	// Image sequences can't be downloaded as a single file
+	if req.format() == ffmpeg.FormatFrames {
+		return nil, fmt.Errorf("format %s isn't supported", ffmpeg.FormatFrames)
+	}
+	return req, nil
+}
+
+type generateRequest struct {
+	Image       string `json:"image"`
+	Video       string `json:"video"`
+	Text        string `json:"text"`
+	Model       string `json:"model"`
+	Seconds     int    `json:"seconds"`
+	Extend      int    `json:"extend"`
+	Prompts     string `json:"prompts"`
+	Interpolate bool   `json:"interpolate"`
+	Upscale     bool   `json:"upscale"`
+	Watermark   bool   `json:"watermark"`
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+	Portrait    bool   `json:"portrait"`
+	Explore     bool   `json:"explore"`
+	LastFrame   bool   `json:"lastFrame"`
+	Fit         string `json:"fit"`
+	Format      string `json:"format"`
+	Post        string `json:"post"`
+}
+
+func (r *generateRequest) format() string { return r.Format }
+
+func (r *generateRequest) sources() []*string { return []*string{&r.Image, &r.Video} }
+
+func (r *generateRequest) run(ctx context.Context, cfg *Config, output string) error {
+	return generate.Run(ctx, &generate.Config{
+		Token:       cfg.Token,
+		Wait:        cfg.Wait,
+		Debug:       cfg.Debug,
+		Proxy:       cfg.Proxy,
+		Output:      output,
+		Model:       r.Model,
+		Folder:      cfg.Folder,
+		Image:       r.Image,
+		Video:       r.Video,
+		Text:        r.Text,
+		Extend:      r.Extend,
+		Interpolate: r.Interpolate,
+		Upscale:     r.Upscale,
+		Watermark:   r.Watermark,
+		Width:       r.Width,
+		Height:      r.Height,
+		Portrait:    r.Portrait,
+		Explore:     r.Explore,
+		LastFrame:   r.LastFrame,
+		Seconds:     r.Seconds,
+		Prompts:     r.Prompts,
+		Fit:         r.Fit,
+		FFmpeg:      cfg.FFmpeg,
+		Format:      r.Format,
+		Post:        r.Post,
+
+		NotifyURL:    cfg.NotifyURL,
+		NotifySecret: cfg.NotifySecret,
+		OnComplete:   cfg.OnComplete,
+	})
+}
+
+type extendRequest struct {
+	Input       string   `json:"input"`
+	N           int      `json:"n"`
+	Model       string   `json:"model"`
+	Seconds     int      `json:"seconds"`
+	Prompts     string   `json:"prompts"`
+	Interpolate bool     `json:"interpolate"`
+	Upscale     bool     `json:"upscale"`
+	Watermark   bool     `json:"watermark"`
+	Explore     bool     `json:"explore"`
+	TrimFrames  int      `json:"trimFrames"`
+	Crossfade   duration `json:"crossfade"`
+	ColorMatch  bool     `json:"colorMatch"`
+	SeedFrame   string   `json:"seedFrame"`
+	Format      string   `json:"format"`
+}
+
+func (r *extendRequest) format() string { return r.Format }
+
+func (r *extendRequest) sources() []*string { return []*string{&r.Input} }
+
+func (r *extendRequest) run(ctx context.Context, cfg *Config, output string) error {
+	return extend.Run(ctx, &extend.Config{
+		Token:       cfg.Token,
+		Wait:        cfg.Wait,
+		Debug:       cfg.Debug,
+		Proxy:       cfg.Proxy,
+		Input:       r.Input,
+		Output:      output,
+		N:           r.N,
+		Model:       r.Model,
+		Folder:      cfg.Folder,
+		Interpolate: r.Interpolate,
+		Upscale:     r.Upscale,
+		Watermark:   r.Watermark,
+		Explore:     r.Explore,
+		Seconds:     r.Seconds,
+		Prompts:     r.Prompts,
+		FFmpeg:      cfg.FFmpeg,
+		Normalize:   ffmpeg.NormalizeAuto,
+		TrimFrames:  r.TrimFrames,
+		Crossfade:   time.Duration(r.Crossfade),
+		ColorMatch:  r.ColorMatch,
+		SeedFrame:   r.SeedFrame,
+		Format:      r.Format,
+
+		NotifyURL:    cfg.NotifyURL,
+		NotifySecret: cfg.NotifySecret,
+		OnComplete:   cfg.OnComplete,
+	})
+}
+
+type loopRequest struct {
+	Input     string   `json:"input"`
+	Mode      string   `json:"mode"`
+	Crossfade duration `json:"crossfade"`
+	Repeat    int      `json:"repeat"`
+	Duration  duration `json:"duration"`
+	AudioMode string   `json:"audioMode"`
+	AI        bool     `json:"ai"`
+	Model     string   `json:"model"`
+	Text      string   `json:"text"`
+	Seconds   int      `json:"seconds"`
+	Explore   bool     `json:"explore"`
+	Format    string   `json:"format"`
+}
+
+func (r *loopRequest) format() string { return r.Format }
+
+func (r *loopRequest) sources() []*string { return []*string{&r.Input} }
+
+func (r *loopRequest) run(ctx context.Context, cfg *Config, output string) error {
+	return loop.Run(ctx, &loop.Config{
+		Token:     cfg.Token,
+		Wait:      cfg.Wait,
+		Debug:     cfg.Debug,
+		Proxy:     cfg.Proxy,
+		Folder:    cfg.Folder,
+		Input:     r.Input,
+		Output:    output,
+		FFmpeg:    cfg.FFmpeg,
+		Mode:      r.Mode,
+		Crossfade: time.Duration(r.Crossfade),
+		Repeat:    r.Repeat,
+		Duration:  time.Duration(r.Duration),
+		AudioMode: r.AudioMode,
+		Format:    r.Format,
+		AI:        r.AI,
+		Model:     r.Model,
+		Text:      r.Text,
+		Seconds:   r.Seconds,
+		Explore:   r.Explore,
+
+		NotifyURL:    cfg.NotifyURL,
+		NotifySecret: cfg.NotifySecret,
+		OnComplete:   cfg.OnComplete,
+	})
+}
+
+// duration is a time.Duration encoded as a string like "1.5s" or as a
+// number of seconds.
+type duration time.Duration
+
+func (d *duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		v, err := strconv.ParseFloat(string(b), 64)
+		if err != nil {
+			return fmt.Errorf("invalid duration %s", b)
+		}
+		*d = duration(v * float64(time.Second))
+		return nil
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("invalid duration %q: %w", s, err)
+	}
+	*d = duration(v)
+	return nil
+}
@@ -0,0 +1,331 @@
+package serve
+
+import (
+	"context"
+	"crypto/subtle"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/igolaizola/vidai/pkg/ffmpeg"
+	"github.com/igolaizola/vidai/pkg/input"
+	"github.com/igolaizola/vidai/pkg/jobs"
+	"github.com/igolaizola/vidai/pkg/workspace"
+)
+
+//go:embed openapi.json
+var openAPI []byte
+
+type Config struct {
+	Token  string
+	Wait   time.Duration
+	Debug  bool
+	Proxy  string
+	Folder string
+
+	Addr        string
+	APIKey      string
+	Dir         string
+	InputDir    string
+	AllowURLs   bool
+	Concurrency int
+	FFmpeg      string
+
+	NotifyURL    string
+	NotifySecret string
+	OnComplete   string
+}
+
+// maxBodySize is the maximum size of a job submission.
+const maxBodySize = 1 << 20
+
+// Run serves the REST API until the context is done.
+// Jobs and their outputs are stored in the dir, if it isn't set they are
+// only kept in memory and the outputs are removed when the server stops.
+func Run(ctx context.Context, cfg *Config) error {
+	if cfg.Addr == "" {
+		return fmt.Errorf("addr is required")
+	}
+	if cfg.Token == "" {
+		return fmt.Errorf("token is required")
+	}
+	// Anyone reaching the server can spend credits, so an api key is
+	// required unless only local clients can connect
+	if cfg.APIKey == "" && !loopback(cfg.Addr) {
+		return fmt.Errorf("api key is required to listen on %s, set it or use a loopback address like 127.0.0.1:8080", cfg.Addr)
+	}
+	var inputDir string
+	if cfg.InputDir != "" {
+		abs, err := filepath.Abs(cfg.InputDir)
+		if err != nil {
+			return fmt.Errorf("vidai: couldn't get input dir path: %w", err)
+		}
+		inputDir, err = filepath.EvalSymlinks(abs)
+		if err != nil {
+			return fmt.Errorf("vidai: couldn't resolve input dir: %w", err)
+		}
+	}
+
+	dir := cfg.Dir
+	var state string
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("vidai: couldn't create jobs dir: %w", err)
+		}
+		state = filepath.Join(dir, "jobs.json")
+	} else {
+		ws, err := workspace.New("", "serve", false)
+		if err != nil {
+			return err
+		}
+		defer ws.Close()
+		dir = ws.Dir()
+	}
+
+	s := &server{cfg: cfg, dir: dir, inputDir: inputDir}
+	q, err := jobs.New(&jobs.Config{
+		Path:        state,
+		Concurrency: cfg.Concurrency,
+		Runner:      s.run,
+	})
+	if err != nil {
+		return fmt.Errorf("vidai: couldn't create job queue: %w", err)
+	}
+	s.queue = q
+
+	srv := &http.Server{
+		Addr:              cfg.Addr,
+		Handler:           s.handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	queueDone := make(chan struct{})
+	go func() {
+		defer close(queueDone)
+		q.Run(ctx)
+	}()
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			log.Println(fmt.Errorf("vidai: couldn't shutdown server: %w", err))
+		}
+	}()
+
+	log.Printf("vidai: listening on %s\n", cfg.Addr)
+	err = srv.ListenAndServe()
+	if !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("vidai: couldn't serve: %w", err)
+	}
+	// Wait for running jobs to be interrupted and marked as failed
+	<-queueDone
+	return nil
+}
+
+type server struct {
+	cfg      *Config
+	dir      string
+	inputDir string
+	queue    *jobs.Queue
+}
+
+// loopback returns true if the address only accepts local connections.
+func loopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func (s *server) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /openapi.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(openAPI)
+	})
+	mux.HandleFunc("POST /jobs/{type}", s.auth(s.submit))
+	mux.HandleFunc("GET /jobs", s.auth(s.list))
+	mux.HandleFunc("GET /jobs/{id}", s.auth(s.get))
+	mux.HandleFunc("GET /jobs/{id}/result", s.auth(s.result))
+	mux.HandleFunc("DELETE /jobs/{id}", s.auth(s.cancel))
+	return mux
+}
+
+// auth checks the API key if it is set.
+func (s *server) auth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.cfg.APIKey != "" {
+			key := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if subtle.ConstantTimeCompare([]byte(key), []byte(s.cfg.APIKey)) != 1 {
+				writeError(w, http.StatusUnauthorized, errors.New("invalid api key"))
+				return
+			}
+		}
+		next(w, r)
+	}
+}
+
+func (s *server) submit(w http.ResponseWriter, r *http.Request) {
+	typ := r.PathValue("type")
+	b, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("couldn't read body: %w", err))
+		return
+	}
+	if len(b) == 0 {
+		b = []byte("{}")
+	}
+	if _, err := newRequest(typ); err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	if _, err := s.decode(typ, b); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	job, err := s.queue.Submit(typ, b)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, job)
+}
+
+func (s *server) list(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.queue.List())
+}
+
+func (s *server) get(w http.ResponseWriter, r *http.Request) {
+	job, err := s.queue.Get(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, job)
+}
+
+func (s *server) result(w http.ResponseWriter, r *http.Request) {
+	job, err := s.queue.Get(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	if job.Status != jobs.StatusSucceeded {
+		writeError(w, http.StatusConflict, fmt.Errorf("job is %s", job.Status))
+		return
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", job.ID+filepath.Ext(job.Output)))
+	http.ServeFile(w, r, job.Output)
+}
+
+func (s *server) cancel(w http.ResponseWriter, r *http.Request) {
+	job, err := s.queue.Cancel(r.PathValue("id"))
+	switch {
+	case errors.Is(err, jobs.ErrNotFound):
+		writeError(w, http.StatusNotFound, err)
+	case errors.Is(err, jobs.ErrFinished):
+		writeError(w, http.StatusConflict, err)
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, err)
+	default:
+		writeJSON(w, http.StatusAccepted, job)
+	}
+}
+
+// run runs a job with the cmd package of its type.
+func (s *server) run(ctx context.Context, job *jobs.Job) (string, error) {
+	req, err := s.decode(job.Type, job.Request)
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(s.dir, job.ID)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("vidai: couldn't create job dir: %w", err)
+	}
+	output := filepath.Join(dir, "output"+ffmpeg.Extension(req.format()))
+	log.Printf("vidai: job %s (%s) started\n", job.ID, job.Type)
+	if err := req.run(ctx, s.cfg, output); err != nil {
+		log.Printf("vidai: job %s failed: %v\n", job.ID, err)
+		return "", err
+	}
+	log.Printf("vidai: job %s done\n", job.ID)
+	return output, nil
+}
+
+// decode decodes a job request and checks its sources.
+// Sources are checked again when the job runs because the server may have
+// been restarted with other options.
+func (s *server) decode(typ string, b []byte) (request, error) {
+	req, err := decodeRequest(typ, b)
+	if err != nil {
+		return nil, err
+	}
+	for _, src := range req.sources() {
+		v, err := s.source(*src)
+		if err != nil {
+			return nil, err
+		}
+		*src = v
+	}
+	return req, nil
+}
+
+// source checks that a source can be used by a job and returns the value
+// passed to the cmd packages. Runway assets are always allowed, urls only if
+// they are enabled and local paths only inside the input dir.
+func (s *server) source(src string) (string, error) {
+	if _, ok := input.Asset(src); ok || src == "" {
+		return src, nil
+	}
+	switch {
+	case src == "-":
+		return "", fmt.Errorf("stdin can't be used as source")
+	case input.IsURL(src):
+		if !s.cfg.AllowURLs {
+			return "", fmt.Errorf("url sources aren't allowed")
+		}
+		return src, nil
+	case s.inputDir == "":
+		return "", fmt.Errorf("local sources aren't allowed")
+	}
+	p := src
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(s.inputDir, p)
+	}
+	// Resolve symlinks so that they can't point outside the input dir
+	resolved, err := filepath.EvalSymlinks(p)
+	if err != nil {
+		return "", fmt.Errorf("source %s not found in the input dir", src)
+	}
+	rel, err := filepath.Rel(s.inputDir, resolved)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("source %s is outside the input dir", src)
+	}
+	return resolved, nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Println(fmt.Errorf("vidai: couldn't write response: %w", err))
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
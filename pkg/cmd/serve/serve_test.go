@@ -0,0 +1,108 @@
+package serve
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoopback(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"127.0.0.1:8080", true},
+		{"localhost:8080", true},
+		{"[::1]:8080", true},
+		{":8080", false},
+		{"0.0.0.0:8080", false},
+		{"192.168.1.10:8080", false},
+		{"example.com:8080", false},
+		{"127.0.0.1", false},
+	}
+	for _, tt := range tests {
+		if got := loopback(tt.addr); got != tt.want {
+			t.Errorf("%q: got %v, want %v", tt.addr, got, tt.want)
+		}
+	}
+}
+
+func TestSource(t *testing.T) {
+	root := t.TempDir()
+	inputDir := filepath.Join(root, "media")
+	if err := os.MkdirAll(filepath.Join(inputDir, "cars"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	inputDir, err := filepath.EvalSymlinks(inputDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	car := filepath.Join(inputDir, "cars", "car.jpg")
+	secret := filepath.Join(root, "secret.txt")
+	for _, f := range []string{car, secret} {
+		if err := os.WriteFile(f, []byte("data"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(secret, filepath.Join(inputDir, "link.jpg")); err != nil {
+		t.Fatal(err)
+	}
+
+	s := &server{cfg: &Config{}, inputDir: inputDir}
+	tests := []struct {
+		src     string
+		want    string
+		wantErr bool
+	}{
+		{src: "", want: ""},
+		{src: "asset:1234", want: "asset:1234"},
+		{src: "cars/car.jpg", want: car},
+		{src: car, want: car},
+		{src: "cars/../cars/car.jpg", want: car},
+		{src: "../secret.txt", wantErr: true},
+		{src: secret, wantErr: true},
+		{src: "link.jpg", wantErr: true},
+		{src: "missing.jpg", wantErr: true},
+		{src: "-", wantErr: true},
+		{src: "https://example.com/car.jpg", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := s.source(tt.src)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%q: expected error", tt.src)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", tt.src, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%q: got %q, want %q", tt.src, got, tt.want)
+		}
+	}
+
+	// Urls are allowed if enabled and local paths are rejected without an
+	// input dir
+	s = &server{cfg: &Config{AllowURLs: true}}
+	if _, err := s.source("https://example.com/car.jpg"); err != nil {
+		t.Error(err)
+	}
+	if _, err := s.source(car); err == nil {
+		t.Error("expected error for local path without input dir")
+	}
+}
+
+func TestDecode(t *testing.T) {
+	s := &server{cfg: &Config{}}
+	if _, err := s.decode(TypeGenerate, []byte(`{"text":"a car","image":"asset:1234"}`)); err != nil {
+		t.Error(err)
+	}
+	if _, err := s.decode(TypeGenerate, []byte(`{"video":"/etc/passwd"}`)); err == nil {
+		t.Error("expected error for local video")
+	}
+	if _, err := s.decode(TypeLoop, []byte(`{"input":"http://169.254.169.254/latest"}`)); err == nil {
+		t.Error("expected error for url input")
+	}
+}
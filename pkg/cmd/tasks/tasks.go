@@ -0,0 +1,216 @@
+package tasks
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/igolaizola/vidai/pkg/runway"
+)
+
+type Config struct {
+	Token string
+	Wait  time.Duration
+	Debug bool
+	Proxy string
+
+	Since    string
+	Until    string
+	Status   string
+	TaskType string
+	Limit    int
+	Format   string
+	Summary  bool
+}
+
+// Run lists the tasks of the account.
+func Run(ctx context.Context, cfg *Config) error {
+	if cfg.Token == "" {
+		return fmt.Errorf("token is required")
+	}
+	format := cfg.Format
+	if format == "" {
+		format = "table"
+	}
+	switch format {
+	case "json", "csv", "table":
+	default:
+		return fmt.Errorf("vidai: unknown format %s", format)
+	}
+	now := time.Now()
+	since, err := parseTime(cfg.Since, now)
+	if err != nil {
+		return fmt.Errorf("vidai: couldn't parse since: %w", err)
+	}
+	until, err := parseTime(cfg.Until, now)
+	if err != nil {
+		return fmt.Errorf("vidai: couldn't parse until: %w", err)
+	}
+
+	client, err := runway.New(&runway.Config{
+		Token: cfg.Token,
+		Wait:  cfg.Wait,
+		Debug: cfg.Debug,
+		Proxy: cfg.Proxy,
+	})
+	if err != nil {
+		return fmt.Errorf("vidai: couldn't create client: %w", err)
+	}
+	tasks, err := client.ListTasks(ctx, &runway.ListTasksRequest{
+		Status:   strings.ToUpper(cfg.Status),
+		TaskType: cfg.TaskType,
+		Since:    since,
+		Until:    until,
+		Limit:    cfg.Limit,
+	})
+	if err != nil {
+		return fmt.Errorf("vidai: couldn't list tasks: %w", err)
+	}
+
+	if cfg.Summary {
+		return writeSummary(os.Stdout, format, summarize(tasks))
+	}
+	return writeTasks(os.Stdout, format, tasks)
+}
+
+// parseTime parses a duration relative to now (e.g. 24h) or a date in
+// RFC3339 or YYYY-MM-DD format. Empty values return the zero time.
+func parseTime(v string, now time.Time) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(v); err == nil {
+		return now.Add(-d), nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", v, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid duration or date %q", v)
+	}
+	return t, nil
+}
+
+var taskHeader = []string{"id", "created", "type", "status", "seconds", "explore", "credits", "reason", "moderation", "url"}
+
+func taskRecord(t *runway.Task) []string {
+	var u string
+	if len(t.ArtifactURLs) > 0 {
+		u = t.ArtifactURLs[0]
+	}
+	return []string{
+		t.ID,
+		t.CreatedAt.Local().Format(time.DateTime),
+		t.TaskType,
+		t.Status,
+		strconv.Itoa(t.Seconds),
+		strconv.FormatBool(t.ExploreMode),
+		strconv.Itoa(t.Credits),
+		t.Reason,
+		t.ModerationCategory,
+		u,
+	}
+}
+
+func writeTasks(w io.Writer, format string, tasks []*runway.Task) error {
+	records := make([][]string, 0, len(tasks))
+	for _, t := range tasks {
+		records = append(records, taskRecord(t))
+	}
+	return write(w, format, tasks, taskHeader, records)
+}
+
+type daySummary struct {
+	Day       string `json:"day"`
+	Tasks     int    `json:"tasks"`
+	Succeeded int    `json:"succeeded"`
+	Failed    int    `json:"failed"`
+	Moderated int    `json:"moderated"`
+	Credits   int    `json:"credits"`
+}
+
+func summarize(tasks []*runway.Task) []*daySummary {
+	days := map[string]*daySummary{}
+	for _, t := range tasks {
+		day := t.CreatedAt.Local().Format(time.DateOnly)
+		s, ok := days[day]
+		if !ok {
+			s = &daySummary{Day: day}
+			days[day] = s
+		}
+		s.Tasks++
+		switch t.Status {
+		case "SUCCEEDED":
+			s.Succeeded++
+		case "FAILED":
+			s.Failed++
+		}
+		if t.Moderated() {
+			s.Moderated++
+		}
+		s.Credits += t.Credits
+	}
+	summaries := make([]*daySummary, 0, len(days))
+	for _, s := range days {
+		summaries = append(summaries, s)
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		return summaries[i].Day > summaries[j].Day
+	})
+	return summaries
+}
+
+var summaryHeader = []string{"day", "tasks", "succeeded", "failed", "moderated", "credits"}
+
+func writeSummary(w io.Writer, format string, summaries []*daySummary) error {
+	records := make([][]string, 0, len(summaries))
+	for _, s := range summaries {
+		records = append(records, []string{
+			s.Day,
+			strconv.Itoa(s.Tasks),
+			strconv.Itoa(s.Succeeded),
+			strconv.Itoa(s.Failed),
+			strconv.Itoa(s.Moderated),
+			strconv.Itoa(s.Credits),
+		})
+	}
+	return write(w, format, summaries, summaryHeader, records)
+}
+
+func write(w io.Writer, format string, v any, header []string, records [][]string) error {
+	switch format {
+	case "json":
+		js, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return fmt.Errorf("vidai: couldn't marshal json: %w", err)
+		}
+		fmt.Fprintln(w, string(js))
+	case "csv":
+		cw := csv.NewWriter(w)
+		if err := cw.Write(header); err != nil {
+			return fmt.Errorf("vidai: couldn't write csv: %w", err)
+		}
+		if err := cw.WriteAll(records); err != nil {
+			return fmt.Errorf("vidai: couldn't write csv: %w", err)
+		}
+	default:
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, strings.ToUpper(strings.Join(header, "\t")))
+		for _, r := range records {
+			fmt.Fprintln(tw, strings.Join(r, "\t"))
+		}
+		if err := tw.Flush(); err != nil {
+			return fmt.Errorf("vidai: couldn't write table: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package watch
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/igolaizola/vidai/pkg/cmd/generate"
+	"gopkg.in/yaml.v2"
+)
+
+// sidecarExts are the extensions of the files read next to each image.
+var sidecarExts = []string{".txt", ".yaml", ".yml"}
+
+// sidecar overrides the defaults of a single image. Fields are pointers so
+// that zero values like `extend: 0` override the defaults too.
+type sidecar struct {
+	Text    *string `yaml:"text"`
+	Model   *string `yaml:"model"`
+	Seconds *int    `yaml:"seconds"`
+	Extend  *int    `yaml:"extend"`
+	Prompts *string `yaml:"prompts"`
+	Fit     *string `yaml:"fit"`
+	Format  *string `yaml:"format"`
+}
+
+// merge overrides the values of the sidecar with the ones set in v.
+func (sc *sidecar) merge(v *sidecar) {
+	if v.Text != nil {
+		sc.Text = v.Text
+	}
+	if v.Model != nil {
+		sc.Model = v.Model
+	}
+	if v.Seconds != nil {
+		sc.Seconds = v.Seconds
+	}
+	if v.Extend != nil {
+		sc.Extend = v.Extend
+	}
+	if v.Prompts != nil {
+		sc.Prompts = v.Prompts
+	}
+	if v.Fit != nil {
+		sc.Fit = v.Fit
+	}
+	if v.Format != nil {
+		sc.Format = v.Format
+	}
+}
+
+// apply sets the values of the sidecar in the generate config.
+func (sc *sidecar) apply(cfg *generate.Config) {
+	if sc.Text != nil {
+		cfg.Text = *sc.Text
+	}
+	if sc.Model != nil {
+		cfg.Model = *sc.Model
+	}
+	if sc.Seconds != nil {
+		cfg.Seconds = *sc.Seconds
+	}
+	if sc.Extend != nil {
+		cfg.Extend = *sc.Extend
+	}
+	if sc.Prompts != nil {
+		cfg.Prompts = *sc.Prompts
+	}
+	if sc.Fit != nil {
+		cfg.Fit = *sc.Fit
+	}
+	if sc.Format != nil {
+		cfg.Format = *sc.Format
+	}
+}
+
+// sidecarFiles returns the existing sidecar files of an image.
+func sidecarFiles(image string) []string {
+	base := strings.TrimSuffix(image, filepath.Ext(image))
+	var files []string
+	for _, ext := range sidecarExts {
+		if _, err := os.Stat(base + ext); err == nil {
+			files = append(files, base+ext)
+		}
+	}
+	return files
+}
+
+// readSidecar reads the sidecar files of an image. Text files contain the
+// prompt and yaml files can set any of the sidecar fields, overriding the
+// prompt of the text file.
+func readSidecar(image string) (*sidecar, error) {
+	var sc sidecar
+	for _, file := range sidecarFiles(image) {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("vidai: couldn't read sidecar: %w", err)
+		}
+		if filepath.Ext(file) == ".txt" {
+			text := strings.TrimSpace(string(b))
+			sc.Text = &text
+			continue
+		}
+		var v sidecar
+		if err := yaml.UnmarshalStrict(b, &v); err != nil {
+			return nil, fmt.Errorf("vidai: couldn't parse sidecar %s: %w", filepath.Base(file), err)
+		}
+		sc.merge(&v)
+	}
+	return &sc, nil
+}
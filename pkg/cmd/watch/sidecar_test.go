@@ -0,0 +1,74 @@
+package watch
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/igolaizola/vidai/pkg/cmd/generate"
+)
+
+func TestReadSidecar(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  generate.Config
+		err   bool
+	}{
+		{
+			name: "no sidecar",
+			want: generate.Config{Text: "default", Extend: 2, Seconds: 5},
+		},
+		{
+			name:  "text",
+			files: map[string]string{"car.txt": " a red car \n"},
+			want:  generate.Config{Text: "a red car", Extend: 2, Seconds: 5},
+		},
+		{
+			name: "yaml keeps text",
+			files: map[string]string{
+				"car.txt":  "a red car",
+				"car.yaml": "model: gen3\nextend: 0\n",
+			},
+			want: generate.Config{Text: "a red car", Model: "gen3", Seconds: 5},
+		},
+		{
+			name: "yaml overrides text",
+			files: map[string]string{
+				"car.txt": "a red car",
+				"car.yml": "text: a blue car\nseconds: 10\nprompts: 'drive|stop'\n",
+			},
+			want: generate.Config{Text: "a blue car", Extend: 2, Seconds: 10, Prompts: "drive|stop"},
+		},
+		{
+			name:  "unknown field",
+			files: map[string]string{"car.yaml": "speed: 10\n"},
+			err:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, content := range tt.files {
+				if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			sc, err := readSidecar(filepath.Join(dir, "car.jpg"))
+			if tt.err {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := generate.Config{Text: "default", Extend: 2, Seconds: 5}
+			sc.apply(&got)
+			if got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package watch
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// errInterrupted is the error of the files found running after a restart.
+// They may have been partially generated, so they are moved to the failed
+// directory instead of generated again to avoid spending credits twice.
+const errInterrupted = "vidai: interrupted"
+
+// File statuses in the state file.
+const (
+	statusRunning = "running"
+	statusDone    = "done"
+	statusFailed  = "failed"
+)
+
+// entry is the processing state of an input file. Size and modification
+// time identify the file, so a new file dropped with the same name is
+// processed again.
+type entry struct {
+	Status    string    `json:"status"`
+	Size      int64     `json:"size"`
+	ModTime   time.Time `json:"modTime"`
+	Output    string    `json:"output,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+func (e *entry) matches(info os.FileInfo) bool {
+	return e.Size == info.Size() && e.ModTime.Equal(info.ModTime())
+}
+
+// state is persisted after each change so that a restarted watcher doesn't
+// generate finished files again and fails the interrupted ones.
+type state struct {
+	path  string
+	Files map[string]*entry `json:"files"`
+}
+
+func loadState(path string) (*state, error) {
+	s := &state{path: path, Files: map[string]*entry{}}
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("vidai: couldn't read state %s: %w", path, err)
+	}
+	if len(b) == 0 {
+		return s, nil
+	}
+	if err := json.Unmarshal(b, s); err != nil {
+		return nil, fmt.Errorf("vidai: couldn't unmarshal state %s: %w", path, err)
+	}
+	if s.Files == nil {
+		s.Files = map[string]*entry{}
+	}
+	return s, nil
+}
+
+func (s *state) set(name string, e *entry) error {
+	e.UpdatedAt = time.Now().UTC()
+	s.Files[name] = e
+	return s.save()
+}
+
+func (s *state) save() error {
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("vidai: couldn't marshal state: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return fmt.Errorf("vidai: couldn't create state dir: %w", err)
+	}
+	// Write to a temp file and rename it to avoid corrupting the state
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return fmt.Errorf("vidai: couldn't write %s: %w", tmp, err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("vidai: couldn't rename %s: %w", tmp, err)
+	}
+	return nil
+}
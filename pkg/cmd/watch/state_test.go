@@ -0,0 +1,78 @@
+package watch
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "watch.json")
+
+	// A missing state is empty
+	s, err := loadState(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Files) != 0 {
+		t.Fatalf("expected empty state, got %d files", len(s.Files))
+	}
+
+	modTime := time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC)
+	if err := s.set("car.jpg", &entry{Status: statusDone, Size: 10, ModTime: modTime, Output: "out/car.mp4"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.set("boat.jpg", &entry{Status: statusRunning, Size: 20, ModTime: modTime}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("temp file wasn't removed: %v", err)
+	}
+
+	loaded, err := loadState(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded.Files) != 2 {
+		t.Fatalf("expected 2 files, got %d", len(loaded.Files))
+	}
+	car := loaded.Files["car.jpg"]
+	if car == nil || car.Status != statusDone || car.Output != "out/car.mp4" || car.UpdatedAt.IsZero() {
+		t.Errorf("unexpected entry %+v", car)
+	}
+	boat := loaded.Files["boat.jpg"]
+	if boat == nil || boat.Status != statusRunning {
+		t.Fatalf("unexpected entry %+v", boat)
+	}
+	if !boat.matches(fileInfo{size: 20, modTime: modTime}) {
+		t.Error("expected entry to match the file")
+	}
+	if boat.matches(fileInfo{size: 21, modTime: modTime}) {
+		t.Error("expected entry not to match a file with another size")
+	}
+
+	// An empty state file is valid and an invalid one is an error
+	if err := os.WriteFile(path, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if s, err := loadState(path); err != nil || len(s.Files) != 0 {
+		t.Errorf("expected empty state, got %v %v", s, err)
+	}
+	if err := os.WriteFile(path, []byte("{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadState(path); err == nil {
+		t.Error("expected error")
+	}
+}
+
+// fileInfo is an os.FileInfo with the fields used to identify files.
+type fileInfo struct {
+	os.FileInfo
+	size    int64
+	modTime time.Time
+}
+
+func (f fileInfo) Size() int64        { return f.size }
+func (f fileInfo) ModTime() time.Time { return f.modTime }
@@ -0,0 +1,382 @@
+package watch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/igolaizola/vidai/pkg/cmd/generate"
+	"github.com/igolaizola/vidai/pkg/ffmpeg"
+	"github.com/igolaizola/vidai/pkg/runway"
+)
+
+// imageExts are the extensions of the files processed by the watcher.
+var imageExts = map[string]bool{
+	".jpg":  true,
+	".jpeg": true,
+	".png":  true,
+	".gif":  true,
+	".bmp":  true,
+	".tif":  true,
+	".tiff": true,
+	".webp": true,
+}
+
+type Config struct {
+	Token   string
+	Wait    time.Duration
+	Debug   bool
+	Proxy   string
+	Folder  string
+	Explore bool
+
+	Dir       string
+	Out       string
+	DoneDir   string
+	FailedDir string
+	State     string
+	Interval  time.Duration
+	Once      bool
+
+	// Defaults that can be overridden by sidecar files
+	Text    string
+	Model   string
+	Seconds int
+	Extend  int
+	Fit     string
+	Format  string
+	FFmpeg  string
+
+	NotifyURL    string
+	NotifySecret string
+	OnComplete   string
+}
+
+// Run watches a directory and generates a video from each new image.
+// Images are processed once they and their sidecar files stop changing, then
+// they are moved with their sidecar files to the done or failed directories.
+func Run(ctx context.Context, cfg *Config) error {
+	if cfg.Dir == "" {
+		return fmt.Errorf("dir is required")
+	}
+	if cfg.Out == "" {
+		return fmt.Errorf("out is required")
+	}
+	if cfg.Token == "" {
+		return fmt.Errorf("token is required")
+	}
+	// Check the token before processing any file, otherwise all the files
+	// would be moved to the failed dir
+	if _, err := runway.New(&runway.Config{Token: cfg.Token, Proxy: cfg.Proxy}); err != nil {
+		return fmt.Errorf("vidai: couldn't create client: %w", err)
+	}
+	interval := cfg.Interval
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	w := &watcher{
+		cfg:       cfg,
+		doneDir:   cfg.DoneDir,
+		failedDir: cfg.FailedDir,
+		seen:      map[string]string{},
+	}
+	w.generator = w.generate
+	if w.doneDir == "" {
+		w.doneDir = filepath.Join(cfg.Dir, "done")
+	}
+	if w.failedDir == "" {
+		w.failedDir = filepath.Join(cfg.Dir, "failed")
+	}
+	for _, d := range []string{cfg.Out, w.doneDir, w.failedDir} {
+		if err := os.MkdirAll(d, 0o755); err != nil {
+			return fmt.Errorf("vidai: couldn't create dir: %w", err)
+		}
+	}
+	statePath := cfg.State
+	if statePath == "" {
+		statePath = filepath.Join(cfg.Dir, ".vidai-watch.json")
+	}
+	st, err := loadState(statePath)
+	if err != nil {
+		return err
+	}
+	w.state = st
+
+	log.Printf("vidai: watching %s\n", cfg.Dir)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := w.poll(ctx); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			var fatal *fatalError
+			if errors.As(err, &fatal) {
+				return fatal.err
+			}
+			log.Println(err)
+		}
+		if cfg.Once {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// fatalError stops the watcher instead of being logged.
+type fatalError struct {
+	err error
+}
+
+func (e *fatalError) Error() string { return e.err.Error() }
+
+func (e *fatalError) Unwrap() error { return e.err }
+
+type watcher struct {
+	cfg       *Config
+	doneDir   string
+	failedDir string
+	state     *state
+	// seen has the stamps of the files of the previous poll to detect when
+	// they are completely written
+	seen map[string]string
+	// generator generates the video of an image and returns its path
+	generator func(ctx context.Context, image string) (string, error)
+}
+
+// poll processes the images that didn't change since the previous poll.
+func (w *watcher) poll(ctx context.Context) error {
+	entries, err := os.ReadDir(w.cfg.Dir)
+	if err != nil {
+		return fmt.Errorf("vidai: couldn't read dir: %w", err)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+
+	seen := map[string]string{}
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || strings.HasPrefix(name, ".") || !imageExts[strings.ToLower(filepath.Ext(name))] {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		st := stamp(filepath.Join(w.cfg.Dir, name), info)
+		seen[name] = st
+
+		// Files found in the state are finished right away
+		prev, ok := w.seen[name]
+		stable := ok && prev == st
+		known := w.state.Files[name] != nil && w.state.Files[name].matches(info)
+		if !stable && !known && !w.cfg.Once {
+			continue
+		}
+		if err := w.process(ctx, name, info); err != nil {
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+	w.seen = seen
+	return nil
+}
+
+// process generates the video of an image and moves it to the done or
+// failed directory. Only errors that should stop the poll are returned, and
+// a fatalError if the watcher can't continue.
+func (w *watcher) process(ctx context.Context, name string, info os.FileInfo) error {
+	path := filepath.Join(w.cfg.Dir, name)
+	if e := w.state.Files[name]; e != nil && e.matches(info) {
+		switch e.Status {
+		case statusDone:
+			// The process stopped before the file was moved
+			_, err := w.move(path, w.doneDir)
+			return err
+		case statusFailed:
+			return w.fail(path, e.Error)
+		case statusRunning:
+			// The process stopped while the image was being generated,
+			// see errInterrupted
+			log.Printf("vidai: %s was interrupted\n", name)
+			e.Status = statusFailed
+			e.Error = errInterrupted
+			if err := w.state.set(name, e); err != nil {
+				return err
+			}
+			return w.fail(path, e.Error)
+		}
+	}
+
+	e := &entry{Status: statusRunning, Size: info.Size(), ModTime: info.ModTime()}
+	if err := w.state.set(name, e); err != nil {
+		return err
+	}
+	log.Printf("vidai: processing %s\n", name)
+	output, err := w.generator(ctx, path)
+	if err != nil && runway.IsAuthError(err) {
+		// The token can't be used anymore, so the remaining images would
+		// fail too. The image is left to be processed with a new token.
+		delete(w.state.Files, name)
+		if err := w.state.save(); err != nil {
+			log.Println(err)
+		}
+		return &fatalError{err: fmt.Errorf("vidai: couldn't process %s: %w", name, err)}
+	}
+	if ctx.Err() != nil {
+		// Keep the running status to mark the file as interrupted after a
+		// restart
+		return ctx.Err()
+	}
+	if err != nil {
+		log.Printf("vidai: %s failed: %v\n", name, err)
+		e.Status = statusFailed
+		e.Error = err.Error()
+		if err := w.state.set(name, e); err != nil {
+			return err
+		}
+		return w.fail(path, e.Error)
+	}
+	log.Printf("vidai: %s done: %s\n", name, output)
+	e.Status = statusDone
+	e.Output = output
+	if err := w.state.set(name, e); err != nil {
+		return err
+	}
+	_, err = w.move(path, w.doneDir)
+	return err
+}
+
+// fail moves the image to the failed directory and writes the reason to an
+// error file named after the moved image.
+func (w *watcher) fail(image, reason string) error {
+	dst, err := w.move(image, w.failedDir)
+	if err != nil {
+		return err
+	}
+	errFile := strings.TrimSuffix(dst, filepath.Ext(dst)) + ".error.txt"
+	if err := os.WriteFile(errFile, []byte(reason+"\n"), 0o644); err != nil {
+		log.Println(fmt.Errorf("vidai: couldn't write error file: %w", err))
+	}
+	return nil
+}
+
+// generate runs generate with the defaults and the sidecar values.
+func (w *watcher) generate(ctx context.Context, image string) (string, error) {
+	sc, err := readSidecar(image)
+	if err != nil {
+		return "", err
+	}
+	cfg := w.cfg
+	gen := &generate.Config{
+		Token:       cfg.Token,
+		Wait:        cfg.Wait,
+		Debug:       cfg.Debug,
+		Proxy:       cfg.Proxy,
+		Folder:      cfg.Folder,
+		Explore:     cfg.Explore,
+		Image:       image,
+		Text:        cfg.Text,
+		Model:       cfg.Model,
+		Seconds:     cfg.Seconds,
+		Extend:      cfg.Extend,
+		Fit:         cfg.Fit,
+		Format:      cfg.Format,
+		Interpolate: true,
+		FFmpeg:      cfg.FFmpeg,
+
+		NotifyURL:    cfg.NotifyURL,
+		NotifySecret: cfg.NotifySecret,
+		OnComplete:   cfg.OnComplete,
+	}
+	sc.apply(gen)
+	base := strings.TrimSuffix(filepath.Base(image), filepath.Ext(image))
+	gen.Output = uniquePath(filepath.Join(cfg.Out, base), ffmpeg.Extension(gen.Format))
+	if err := generate.Run(ctx, gen); err != nil {
+		return "", err
+	}
+	return gen.Output, nil
+}
+
+// stamp identifies the contents of an image and its sidecar files by their
+// sizes and modification times, so that an image isn't processed while any
+// of its files is still being written.
+func stamp(image string, info os.FileInfo) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d-%d", info.Size(), info.ModTime().UnixNano())
+	base := strings.TrimSuffix(image, filepath.Ext(image))
+	for _, ext := range sidecarExts {
+		fi, err := os.Stat(base + ext)
+		if err != nil {
+			continue
+		}
+		fmt.Fprintf(&b, ";%s-%d-%d", ext, fi.Size(), fi.ModTime().UnixNano())
+	}
+	return b.String()
+}
+
+// move moves the image and its sidecar files to the directory and returns
+// the new path of the image.
+func (w *watcher) move(image, dir string) (string, error) {
+	var moved string
+	for _, file := range append([]string{image}, sidecarFiles(image)...) {
+		ext := filepath.Ext(file)
+		dst := uniquePath(filepath.Join(dir, strings.TrimSuffix(filepath.Base(file), ext)), ext)
+		if err := moveFile(file, dst); err != nil {
+			return "", fmt.Errorf("vidai: couldn't move %s: %w", file, err)
+		}
+		if moved == "" {
+			moved = dst
+		}
+	}
+	return moved, nil
+}
+
+// uniquePath returns base+ext or base-N+ext if the file already exists.
+func uniquePath(base, ext string) string {
+	p := base + ext
+	for i := 1; ; i++ {
+		if _, err := os.Stat(p); os.IsNotExist(err) {
+			return p
+		}
+		p = fmt.Sprintf("%s-%d%s", base, i, ext)
+	}
+}
+
+// moveFile renames the file, copying it if the destination is in another
+// device.
+func moveFile(src, dst string) error {
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	_ = in.Close()
+	return os.Remove(src)
+}
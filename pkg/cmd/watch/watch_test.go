@@ -0,0 +1,228 @@
+package watch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/igolaizola/vidai/pkg/runway"
+)
+
+// newTestWatcher returns a watcher of a temp dir that records the images
+// it generates.
+func newTestWatcher(t *testing.T, generated *[]string) *watcher {
+	t.Helper()
+	dir := t.TempDir()
+	w := &watcher{
+		cfg:       &Config{Dir: filepath.Join(dir, "in"), Out: filepath.Join(dir, "out")},
+		doneDir:   filepath.Join(dir, "done"),
+		failedDir: filepath.Join(dir, "failed"),
+		seen:      map[string]string{},
+	}
+	for _, d := range []string{w.cfg.Dir, w.cfg.Out, w.doneDir, w.failedDir} {
+		if err := os.MkdirAll(d, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	st, err := loadState(filepath.Join(w.cfg.Dir, ".vidai-watch.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.state = st
+	w.generator = func(_ context.Context, image string) (string, error) {
+		*generated = append(*generated, filepath.Base(image))
+		return filepath.Join(w.cfg.Out, "video.mp4"), nil
+	}
+	return w
+}
+
+func writeFile(t *testing.T, path, content string) os.FileInfo {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return info
+}
+
+func TestPollRestart(t *testing.T) {
+	var generated []string
+	w := newTestWatcher(t, &generated)
+	running := writeFile(t, filepath.Join(w.cfg.Dir, "running.jpg"), "running")
+	done := writeFile(t, filepath.Join(w.cfg.Dir, "done.jpg"), "done")
+	writeFile(t, filepath.Join(w.cfg.Dir, "new.jpg"), "new")
+	if err := w.state.set("running.jpg", &entry{Status: statusRunning, Size: running.Size(), ModTime: running.ModTime()}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.state.set("done.jpg", &entry{Status: statusDone, Size: done.Size(), ModTime: done.ModTime()}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Known files are handled on the first poll, new ones wait for the next
+	if err := w.poll(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(generated) != 0 {
+		t.Fatalf("expected no generation, got %v", generated)
+	}
+	if _, err := os.Stat(filepath.Join(w.doneDir, "done.jpg")); err != nil {
+		t.Errorf("done.jpg wasn't moved: %v", err)
+	}
+
+	// Interrupted files aren't generated again
+	if _, err := os.Stat(filepath.Join(w.failedDir, "running.jpg")); err != nil {
+		t.Errorf("running.jpg wasn't moved: %v", err)
+	}
+	if b, err := os.ReadFile(filepath.Join(w.failedDir, "running.error.txt")); err != nil || string(b) != errInterrupted+"\n" {
+		t.Errorf("unexpected error file %q %v", b, err)
+	}
+	if e := w.state.Files["running.jpg"]; e.Status != statusFailed || e.Error != errInterrupted {
+		t.Errorf("unexpected entry %+v", e)
+	}
+
+	if err := w.poll(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(generated) != 1 || generated[0] != "new.jpg" {
+		t.Errorf("expected new.jpg to be generated, got %v", generated)
+	}
+}
+
+func TestPollInterrupted(t *testing.T) {
+	var generated []string
+	w := newTestWatcher(t, &generated)
+	ctx, cancel := context.WithCancel(context.Background())
+	w.generator = func(context.Context, string) (string, error) {
+		cancel()
+		return "", ctx.Err()
+	}
+	info := writeFile(t, filepath.Join(w.cfg.Dir, "car.jpg"), "car")
+	w.cfg.Once = true
+	if err := w.poll(ctx); err == nil {
+		t.Fatal("expected error")
+	}
+
+	// The file is kept with the running status to be failed after a restart
+	loaded, err := loadState(w.state.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := loaded.Files["car.jpg"]; e == nil || e.Status != statusRunning || !e.matches(info) {
+		t.Errorf("unexpected entry %+v", e)
+	}
+	if _, err := os.Stat(filepath.Join(w.cfg.Dir, "car.jpg")); err != nil {
+		t.Errorf("file was moved: %v", err)
+	}
+}
+
+func TestPollSidecar(t *testing.T) {
+	var generated []string
+	w := newTestWatcher(t, &generated)
+	image := filepath.Join(w.cfg.Dir, "car.jpg")
+	writeFile(t, image, "car")
+	if err := w.poll(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// The image is stable but its sidecar is still being written
+	sidecar := filepath.Join(w.cfg.Dir, "car.yaml")
+	writeFile(t, sidecar, "model: gen3\n")
+	if err := w.poll(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(generated) != 0 {
+		t.Fatalf("expected no generation, got %v", generated)
+	}
+	future := time.Now().Add(time.Minute)
+	if err := os.Chtimes(sidecar, future, future); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.poll(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(generated) != 0 {
+		t.Fatalf("expected no generation, got %v", generated)
+	}
+
+	if err := w.poll(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(generated) != 1 {
+		t.Fatalf("expected car.jpg to be generated, got %v", generated)
+	}
+	for _, name := range []string{"car.jpg", "car.yaml"} {
+		if _, err := os.Stat(filepath.Join(w.doneDir, name)); err != nil {
+			t.Errorf("%s wasn't moved: %v", name, err)
+		}
+	}
+}
+
+func TestPollFailed(t *testing.T) {
+	var generated []string
+	w := newTestWatcher(t, &generated)
+	w.cfg.Once = true
+	image := filepath.Join(w.cfg.Dir, "car.jpg")
+
+	// Failures of images with the same name keep their own error files
+	for i, content := range []string{"car", "another car"} {
+		reason := fmt.Sprintf("boom %d", i)
+		w.generator = func(context.Context, string) (string, error) {
+			return "", errors.New(reason)
+		}
+		writeFile(t, image, content)
+		if err := w.poll(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range []string{"car", "car-1"} {
+		if _, err := os.Stat(filepath.Join(w.failedDir, name+".jpg")); err != nil {
+			t.Errorf("%s.jpg wasn't moved: %v", name, err)
+		}
+	}
+	for name, want := range map[string]string{"car": "boom 0\n", "car-1": "boom 1\n"} {
+		b, err := os.ReadFile(filepath.Join(w.failedDir, name+".error.txt"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != want {
+			t.Errorf("%s: got error %q, want %q", name, b, want)
+		}
+	}
+}
+
+func TestPollAuthError(t *testing.T) {
+	var generated []string
+	w := newTestWatcher(t, &generated)
+	w.cfg.Once = true
+	w.generator = func(context.Context, string) (string, error) {
+		return "", fmt.Errorf("vidai: couldn't create client: %w", runway.ErrTokenExpired)
+	}
+	writeFile(t, filepath.Join(w.cfg.Dir, "car.jpg"), "car")
+	writeFile(t, filepath.Join(w.cfg.Dir, "boat.jpg"), "boat")
+
+	// The watcher stops and the images are left in place
+	err := w.poll(context.Background())
+	var fatal *fatalError
+	if !errors.As(err, &fatal) || !errors.Is(err, runway.ErrTokenExpired) {
+		t.Fatalf("expected fatal token error, got %v", err)
+	}
+	for _, name := range []string{"car.jpg", "boat.jpg"} {
+		if _, err := os.Stat(filepath.Join(w.cfg.Dir, name)); err != nil {
+			t.Errorf("%s was moved: %v", name, err)
+		}
+	}
+	loaded, err := loadState(w.state.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded.Files) != 0 {
+		t.Errorf("expected empty state, got %v", loaded.Files)
+	}
+}
@@ -0,0 +1,68 @@
+package ffmpeg
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// AudioOptions configures how an audio track is added to a video.
+type AudioOptions struct {
+	// FadeIn and FadeOut durations of the audio (optional).
+	FadeIn  time.Duration
+	FadeOut time.Duration
+	// NoLoop leaves the end of the video silent if the audio is shorter
+	// instead of looping the audio.
+	NoLoop bool
+}
+
+// AddAudio replaces the audio of the video with the audio track.
+// The audio is trimmed or looped to the video length and the video isn't
+// encoded again.
+func (f *FFmpeg) AddAudio(ctx context.Context, video, audio, output string, opts *AudioOptions) error {
+	if opts == nil {
+		opts = &AudioOptions{}
+	}
+	vinfo, err := f.Probe(ctx, video)
+	if err != nil {
+		return err
+	}
+	ainfo, err := f.Probe(ctx, audio)
+	if err != nil {
+		return err
+	}
+	if !ainfo.HasAudio {
+		return fmt.Errorf("ffmpeg: %s has no audio", audio)
+	}
+
+	d := vinfo.Duration
+	end := d
+	args := []string{"-i", video}
+	if ainfo.Duration < d {
+		if opts.NoLoop {
+			end = ainfo.Duration
+		} else {
+			args = append(args, "-stream_loop", "-1")
+		}
+	}
+	args = append(args, "-i", audio)
+
+	filters := []string{fmt.Sprintf("atrim=end=%s", seconds(d)), "asetpts=PTS-STARTPTS"}
+	if opts.FadeIn > 0 {
+		filters = append(filters, fmt.Sprintf("afade=t=in:st=0:d=%s", seconds(opts.FadeIn)))
+	}
+	if opts.FadeOut > 0 {
+		st := end - opts.FadeOut
+		if st < 0 {
+			st = 0
+		}
+		filters = append(filters, fmt.Sprintf("afade=t=out:st=%s:d=%s", seconds(st), seconds(opts.FadeOut)))
+	}
+	args = append(args, "-map", "0:v:0", "-map", "1:a:0", "-af", strings.Join(filters, ","),
+		"-c:v", "copy", "-c:a", "aac", "-t", seconds(d), output)
+	if err := f.run(ctx, "add audio", d, args...); err != nil {
+		return fmt.Errorf("ffmpeg: couldn't add audio: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,190 @@
+package ffmpeg
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Edit operations.
+const (
+	OpTrim    = "trim"
+	OpSpeed   = "speed"
+	OpScale   = "scale"
+	OpFill    = "fill"
+	OpReverse = "reverse"
+	OpMute    = "mute"
+)
+
+// Op is an operation of an edit pipeline.
+type Op struct {
+	Name string
+	// Start and End of trim operations, zero end means the end of the video.
+	Start time.Duration
+	End   time.Duration
+	// Speed factor of speed operations.
+	Speed float64
+	// Width and Height of scale and fill operations.
+	Width  int
+	Height int
+}
+
+// ParseOps parses a pipeline of operations separated by commas, e.g.
+// "trim=0:4,speed=0.5,scale=1080x1920,reverse,mute".
+//
+//   - trim=start:end keeps the given range, times are seconds or durations.
+//   - speed=factor changes the speed blending frames to keep the frame rate.
+//   - scale=WxH fits the video inside the size adding black bars.
+//   - fill=WxH scales the video to fill the size cropping the borders.
+//   - reverse plays the video backwards.
+//   - mute removes the audio.
+func ParseOps(spec string) ([]Op, error) {
+	var ops []Op
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, args, _ := strings.Cut(item, "=")
+		op := Op{Name: name}
+		var err error
+		switch name {
+		case OpTrim:
+			start, end, ok := strings.Cut(args, ":")
+			if !ok {
+				return nil, fmt.Errorf("ffmpeg: invalid trim %q, expected start:end", args)
+			}
+			if op.Start, err = parseTime(start); err != nil {
+				return nil, err
+			}
+			if op.End, err = parseTime(end); err != nil {
+				return nil, err
+			}
+			if op.End != 0 && op.End <= op.Start {
+				return nil, fmt.Errorf("ffmpeg: invalid trim %q, end must be after start", args)
+			}
+		case OpSpeed:
+			op.Speed, err = strconv.ParseFloat(args, 64)
+			if err != nil || op.Speed <= 0 {
+				return nil, fmt.Errorf("ffmpeg: invalid speed %q", args)
+			}
+		case OpScale, OpFill:
+			w, h, ok := strings.Cut(args, "x")
+			op.Width, err = strconv.Atoi(w)
+			if err != nil || !ok {
+				return nil, fmt.Errorf("ffmpeg: invalid size %q, expected WxH", args)
+			}
+			op.Height, err = strconv.Atoi(h)
+			if err != nil || op.Width <= 0 || op.Height <= 0 {
+				return nil, fmt.Errorf("ffmpeg: invalid size %q, expected WxH", args)
+			}
+		case OpReverse, OpMute:
+			if args != "" {
+				return nil, fmt.Errorf("ffmpeg: %s doesn't accept arguments", name)
+			}
+		default:
+			return nil, fmt.Errorf("ffmpeg: unknown edit operation %q", name)
+		}
+		ops = append(ops, op)
+	}
+	if len(ops) == 0 {
+		return nil, fmt.Errorf("ffmpeg: no edit operations")
+	}
+	return ops, nil
+}
+
+// parseTime parses seconds or a duration, empty values are zero.
+func parseTime(v string) (time.Duration, error) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0, nil
+	}
+	if s, err := strconv.ParseFloat(v, 64); err == nil {
+		return time.Duration(s * float64(time.Second)), nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, fmt.Errorf("ffmpeg: invalid time %q", v)
+	}
+	return d, nil
+}
+
+// Edit applies the operations to the input in order and encodes the result.
+func (f *FFmpeg) Edit(ctx context.Context, input, output string, ops []Op) error {
+	info, err := f.Probe(ctx, input)
+	if err != nil {
+		return err
+	}
+	fps := info.FPS
+	if fps <= 0 {
+		fps = 24
+	}
+	audio := info.HasAudio
+	total := info.Duration
+
+	var vf, af []string
+	for _, op := range ops {
+		switch op.Name {
+		case OpTrim:
+			v, a := "trim=start="+seconds(op.Start), "atrim=start="+seconds(op.Start)
+			if op.End > 0 {
+				v += ":end=" + seconds(op.End)
+				a += ":end=" + seconds(op.End)
+				total = op.End - op.Start
+			} else {
+				total -= op.Start
+			}
+			vf = append(vf, v, "setpts=PTS-STARTPTS")
+			af = append(af, a, "asetpts=PTS-STARTPTS")
+		case OpSpeed:
+			vf = append(vf, fmt.Sprintf("setpts=PTS/%s", formatFloat(op.Speed)),
+				fmt.Sprintf("minterpolate=fps=%s:mi_mode=blend", formatFloat(fps)))
+			af = append(af, atempo(op.Speed)...)
+			total = time.Duration(float64(total) / op.Speed)
+		case OpScale:
+			vf = append(vf, fmt.Sprintf("scale=%d:%d:force_original_aspect_ratio=decrease,pad=%d:%d:(ow-iw)/2:(oh-ih)/2,setsar=1",
+				op.Width, op.Height, op.Width, op.Height))
+		case OpFill:
+			vf = append(vf, fmt.Sprintf("scale=%d:%d:force_original_aspect_ratio=increase,crop=%d:%d,setsar=1",
+				op.Width, op.Height, op.Width, op.Height))
+		case OpReverse:
+			vf = append(vf, "reverse")
+			af = append(af, "areverse")
+		case OpMute:
+			audio = false
+		}
+	}
+	vf = append(vf, "format=yuv420p")
+
+	args := []string{"-i", input, "-vf", strings.Join(vf, ","), "-c:v", "libx264", "-crf", "18"}
+	if audio {
+		if len(af) > 0 {
+			args = append(args, "-af", strings.Join(af, ","))
+		}
+		args = append(args, "-c:a", "aac")
+	} else {
+		args = append(args, "-an")
+	}
+	args = append(args, output)
+	if err := f.run(ctx, "edit", total, args...); err != nil {
+		return fmt.Errorf("ffmpeg: couldn't edit video: %w", err)
+	}
+	return nil
+}
+
+// atempo returns the atempo filters to change the audio speed, chained
+// because each filter only supports factors between 0.5 and 2.
+func atempo(speed float64) []string {
+	var filters []string
+	for speed > 2 {
+		filters = append(filters, "atempo=2")
+		speed /= 2
+	}
+	for speed < 0.5 {
+		filters = append(filters, "atempo=0.5")
+		speed /= 0.5
+	}
+	return append(filters, "atempo="+formatFloat(speed))
+}
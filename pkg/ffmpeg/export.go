@@ -0,0 +1,118 @@
+package ffmpeg
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Export formats.
+const (
+	FormatMP4    = "mp4"
+	FormatGIF    = "gif"
+	FormatWebM   = "webm"
+	FormatAPNG   = "apng"
+	FormatFrames = "frames"
+)
+
+// ExportOptions configures the output of Export.
+type ExportOptions struct {
+	// Format of the output, defaults to mp4.
+	Format string
+	// FPS is the output frame rate (optional).
+	FPS float64
+	// Width and Height to scale the output to. If only one of them is set,
+	// the aspect ratio is kept (optional).
+	Width  int
+	Height int
+}
+
+// Extension returns the file extension of a format or an empty string for
+// image sequences.
+func Extension(format string) string {
+	switch format {
+	case FormatFrames:
+		return ""
+	case FormatAPNG:
+		return ".png"
+	case "":
+		return ".mp4"
+	default:
+		return "." + format
+	}
+}
+
+// NeedsExport returns whether videos must be exported to use the format.
+func NeedsExport(format string) bool {
+	return format != "" && format != FormatMP4
+}
+
+// Export converts the input to the given format.
+// GIFs use a palette generated from the video to reduce banding. For image
+// sequences the output is a directory where PNG frames are written.
+func (f *FFmpeg) Export(ctx context.Context, input, output string, opts *ExportOptions) error {
+	if opts == nil {
+		opts = &ExportOptions{}
+	}
+	format := opts.Format
+	if format == "" {
+		format = FormatMP4
+	}
+
+	var filters []string
+	if opts.FPS > 0 {
+		filters = append(filters, fmt.Sprintf("fps=%s", formatFloat(opts.FPS)))
+	}
+	if opts.Width > 0 || opts.Height > 0 {
+		w, h := opts.Width, opts.Height
+		if w == 0 {
+			w = -2
+		}
+		if h == 0 {
+			h = -2
+		}
+		filters = append(filters, fmt.Sprintf("scale=%d:%d:flags=lanczos", w, h))
+	}
+	vf := strings.Join(filters, ",")
+
+	args := []string{"-i", input}
+	switch format {
+	case FormatMP4:
+		if vf != "" {
+			args = append(args, "-vf", vf)
+		}
+		args = append(args, "-c:v", "libx264", "-crf", "18", "-pix_fmt", "yuv420p", "-c:a", "aac", output)
+	case FormatWebM:
+		if vf != "" {
+			args = append(args, "-vf", vf)
+		}
+		args = append(args, "-c:v", "libvpx-vp9", "-crf", "32", "-b:v", "0", "-row-mt", "1", "-c:a", "libopus", output)
+	case FormatGIF:
+		palette := "split[s0][s1];[s0]palettegen=stats_mode=diff[p];[s1][p]paletteuse=dither=bayer:bayer_scale=5:diff_mode=rectangle"
+		if vf != "" {
+			palette = vf + "," + palette
+		}
+		args = append(args, "-filter_complex", palette, "-loop", "0", output)
+	case FormatAPNG:
+		if vf != "" {
+			args = append(args, "-vf", vf)
+		}
+		args = append(args, "-plays", "0", "-f", "apng", output)
+	case FormatFrames:
+		if err := os.MkdirAll(output, 0o755); err != nil {
+			return fmt.Errorf("ffmpeg: couldn't create frames dir: %w", err)
+		}
+		if vf != "" {
+			args = append(args, "-vf", vf)
+		}
+		args = append(args, "-vsync", "0", filepath.Join(output, "frame-%05d.png"))
+	default:
+		return fmt.Errorf("ffmpeg: unknown export format %q", format)
+	}
+	if err := f.run(ctx, "export "+format, f.duration(ctx, input), args...); err != nil {
+		return fmt.Errorf("ffmpeg: couldn't export %s: %w", format, err)
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package ffmpeg
+
+import (
+	"context"
+	"io"
+	"sync"
+)
+
+// Call is a command received by the fake runner.
+type Call struct {
+	Name string
+	Args []string
+}
+
+// Fake is a runner that records the commands instead of running them.
+// It is intended to be used in unit tests.
+type Fake struct {
+	// Handler is called for each command and can write to stdout to fake
+	// the output of ffprobe or the progress of ffmpeg (optional).
+	Handler func(name string, args []string, stdout io.Writer) error
+
+	lck   sync.Mutex
+	calls []Call
+}
+
+func (f *Fake) Run(ctx context.Context, name string, args []string, stdout io.Writer) error {
+	f.lck.Lock()
+	f.calls = append(f.calls, Call{Name: name, Args: append([]string{}, args...)})
+	f.lck.Unlock()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if f.Handler == nil {
+		return nil
+	}
+	if stdout == nil {
+		stdout = io.Discard
+	}
+	return f.Handler(name, args, stdout)
+}
+
+// Calls returns the commands received so far.
+func (f *Fake) Calls() []Call {
+	f.lck.Lock()
+	defer f.lck.Unlock()
+	return append([]Call{}, f.calls...)
+}
@@ -0,0 +1,401 @@
+package ffmpeg
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Config struct {
+	// Bin is the path to the ffmpeg binary, defaults to "ffmpeg".
+	Bin string
+	// ProbeBin is the path to the ffprobe binary, defaults to the ffprobe
+	// binary next to ffmpeg.
+	ProbeBin string
+	// Runner runs the commands, defaults to a runner based on os/exec.
+	Runner Runner
+	// Progress is called with the progress of each operation (optional).
+	Progress func(Progress)
+	// Debug logs the progress if no progress callback is set.
+	Debug bool
+	// TempDir is the directory for temporary files, defaults to the system
+	// temp dir.
+	TempDir string
+}
+
+// FFmpeg runs typed operations using the ffmpeg and ffprobe binaries.
+type FFmpeg struct {
+	bin      string
+	probeBin string
+	runner   Runner
+	progress func(Progress)
+	tempDir  string
+}
+
+// New creates a new ffmpeg wrapper.
+func New(cfg *Config) *FFmpeg {
+	if cfg == nil {
+		cfg = &Config{}
+	}
+	bin := cfg.Bin
+	if bin == "" {
+		bin = "ffmpeg"
+	}
+	probeBin := cfg.ProbeBin
+	if probeBin == "" {
+		probeBin = probePath(bin)
+	}
+	runner := cfg.Runner
+	if runner == nil {
+		runner = &execRunner{}
+	}
+	progress := cfg.Progress
+	if progress == nil && cfg.Debug {
+		progress = LogProgress
+	}
+	return &FFmpeg{
+		bin:      bin,
+		probeBin: probeBin,
+		runner:   runner,
+		progress: progress,
+		tempDir:  cfg.TempDir,
+	}
+}
+
+// TempDir returns the directory for temporary files, an empty string means
+// the system temp dir.
+func (f *FFmpeg) TempDir() string {
+	return f.tempDir
+}
+
+// probePath returns the path of the ffprobe binary next to ffmpeg.
+func probePath(bin string) string {
+	dir, base := filepath.Split(bin)
+	i := strings.LastIndex(base, "ffmpeg")
+	if i < 0 {
+		return filepath.Join(dir, "ffprobe")
+	}
+	return dir + base[:i] + "ffprobe" + base[i+len("ffmpeg"):]
+}
+
+// Progress is the progress of an ffmpeg operation.
+type Progress struct {
+	// Operation is the name of the running operation.
+	Operation string
+	// Time is the duration of the output that has already been processed.
+	Time time.Duration
+	// Total is the expected duration of the output, 0 if unknown.
+	Total time.Duration
+	// Done is true when the operation has finished.
+	Done bool
+}
+
+// Ratio returns the progress as a value between 0 and 1 or -1 if unknown.
+func (p Progress) Ratio() float64 {
+	if p.Done {
+		return 1
+	}
+	if p.Total <= 0 {
+		return -1
+	}
+	r := float64(p.Time) / float64(p.Total)
+	if r > 1 {
+		r = 1
+	}
+	return r
+}
+
+// run launches ffmpeg with the common arguments and reports its progress.
+func (f *FFmpeg) run(ctx context.Context, op string, total time.Duration, args ...string) error {
+	base := []string{"-hide_banner", "-loglevel", "error", "-nostdin", "-y"}
+	if f.progress == nil {
+		return f.runner.Run(ctx, f.bin, append(base, args...), nil)
+	}
+	base = append(base, "-progress", "pipe:1", "-nostats")
+
+	pr, pw := io.Pipe()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		parseProgress(pr, func(p Progress) {
+			p.Operation = op
+			p.Total = total
+			f.progress(p)
+		})
+	}()
+	err := f.runner.Run(ctx, f.bin, append(base, args...), pw)
+	_ = pw.Close()
+	<-done
+	return err
+}
+
+// parseProgress parses the key=value output of "-progress" and calls fn each
+// time a progress block is completed.
+func parseProgress(r io.Reader, fn func(Progress)) {
+	var p Progress
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		k, v, ok := strings.Cut(strings.TrimSpace(scanner.Text()), "=")
+		if !ok {
+			continue
+		}
+		switch k {
+		case "out_time_us", "out_time_ms":
+			// Despite its name, out_time_ms is also in microseconds
+			if n, err := strconv.ParseInt(v, 10, 64); err == nil && n >= 0 {
+				p.Time = time.Duration(n) * time.Microsecond
+			}
+		case "progress":
+			p.Done = v == "end"
+			fn(p)
+		}
+	}
+	// Drain the reader so that the writer is never blocked
+	_, _ = io.Copy(io.Discard, r)
+}
+
+// ExtractFrame extracts a single frame from the input into an image.
+// Positive offsets are relative to the start of the video and negative
+// offsets are relative to its end.
+func (f *FFmpeg) ExtractFrame(ctx context.Context, input, output string, at time.Duration) error {
+	var args []string
+	if at < 0 {
+		args = append(args, "-sseof", seconds(at))
+	} else if at > 0 {
+		args = append(args, "-ss", seconds(at))
+	}
+	args = append(args, "-i", input, "-frames:v", "1", "-q:v", "1", output)
+	if err := f.run(ctx, "extract frame", 0, args...); err != nil {
+		return fmt.Errorf("ffmpeg: couldn't extract frame from %s: %w", input, err)
+	}
+	return nil
+}
+
+// ExtractLastFrame extracts the last frame of the input into an image.
+func (f *FFmpeg) ExtractLastFrame(ctx context.Context, input, output string) error {
+	// This will seek to the last second of the input and output all frames.
+	// But since -update 1 is set, each frame will be overwritten to the
+	// same file, leaving only the last frame remaining.
+	args := []string{"-sseof", "-1", "-i", input, "-update", "1", "-q:v", "1", output}
+	if err := f.run(ctx, "extract last frame", 0, args...); err != nil {
+		return fmt.Errorf("ffmpeg: couldn't extract last frame from %s: %w", input, err)
+	}
+	return nil
+}
+
+// ExtractFrames extracts every frame from the given offset to the end of the
+// video. Negative offsets are relative to the end of the video.
+// The output must be a pattern with a sequence number, e.g. frame-%03d.png.
+func (f *FFmpeg) ExtractFrames(ctx context.Context, input, pattern string, from time.Duration) error {
+	var args []string
+	if from < 0 {
+		args = append(args, "-sseof", seconds(from))
+	} else if from > 0 {
+		args = append(args, "-ss", seconds(from))
+	}
+	args = append(args, "-i", input, "-vsync", "0", "-q:v", "1", pattern)
+	if err := f.run(ctx, "extract frames", 0, args...); err != nil {
+		return fmt.Errorf("ffmpeg: couldn't extract frames from %s: %w", input, err)
+	}
+	return nil
+}
+
+// Concat joins the inputs into the output without re-encoding them.
+func (f *FFmpeg) Concat(ctx context.Context, inputs []string, output string) error {
+	if len(inputs) == 0 {
+		return fmt.Errorf("ffmpeg: no inputs to concat")
+	}
+	list, err := os.CreateTemp(f.tempDir, "vidai-concat-*.txt")
+	if err != nil {
+		return fmt.Errorf("ffmpeg: couldn't create list file: %w", err)
+	}
+	defer func() { _ = os.Remove(list.Name()) }()
+	for _, in := range inputs {
+		abs, err := filepath.Abs(in)
+		if err != nil {
+			_ = list.Close()
+			return fmt.Errorf("ffmpeg: couldn't get absolute path of %s: %w", in, err)
+		}
+		if _, err := fmt.Fprintf(list, "file '%s'\n", escapeListPath(abs)); err != nil {
+			_ = list.Close()
+			return fmt.Errorf("ffmpeg: couldn't write list file: %w", err)
+		}
+	}
+	if err := list.Close(); err != nil {
+		return fmt.Errorf("ffmpeg: couldn't write list file: %w", err)
+	}
+
+	args := []string{"-f", "concat", "-safe", "0", "-i", list.Name(), "-c", "copy", output}
+	if err := f.run(ctx, "concat", 0, args...); err != nil {
+		return fmt.Errorf("ffmpeg: couldn't concat videos: %w", err)
+	}
+	return nil
+}
+
+// escapeListPath escapes single quotes for the concat demuxer list file.
+func escapeListPath(p string) string {
+	return strings.ReplaceAll(p, "'", `'\''`)
+}
+
+// Reverse reverses the video of the input.
+func (f *FFmpeg) Reverse(ctx context.Context, input, output string) error {
+	args := []string{"-i", input, "-vf", "reverse", output}
+	if err := f.run(ctx, "reverse", f.duration(ctx, input), args...); err != nil {
+		return fmt.Errorf("ffmpeg: couldn't reverse video: %w", err)
+	}
+	return nil
+}
+
+type TranscodeOptions struct {
+	// Width and Height to scale the video to (optional).
+	Width  int
+	Height int
+	// Pad keeps the aspect ratio when scaling by adding black bars.
+	Pad bool
+	// FPS is the output frame rate (optional).
+	FPS float64
+	// PixFmt is the output pixel format, defaults to yuv420p.
+	PixFmt string
+	// Codec is the output video codec, defaults to libx264.
+	Codec string
+	// CRF is the constant rate factor of the output, defaults to 18.
+	CRF int
+	// Filters are additional video filters applied after scaling (optional).
+	Filters []string
+	// NoAudio removes the audio from the output.
+	NoAudio bool
+	// SampleRate encodes the audio as AAC with this sample rate (optional).
+	SampleRate int
+	// TimeScale is the time scale of the output video track (optional).
+	TimeScale int
+	// Duration limits the length of the output (optional).
+	Duration time.Duration
+}
+
+// Transcode encodes the input again using the given options.
+func (f *FFmpeg) Transcode(ctx context.Context, input, output string, opts *TranscodeOptions) error {
+	if opts == nil {
+		opts = &TranscodeOptions{}
+	}
+	pixFmt := opts.PixFmt
+	if pixFmt == "" {
+		pixFmt = "yuv420p"
+	}
+	codec := opts.Codec
+	if codec == "" {
+		codec = "libx264"
+	}
+	crf := opts.CRF
+	if crf == 0 {
+		crf = 18
+	}
+
+	var filters []string
+	switch {
+	case opts.Width > 0 && opts.Height > 0 && opts.Pad:
+		filters = append(filters,
+			fmt.Sprintf("scale=%d:%d:force_original_aspect_ratio=decrease", opts.Width, opts.Height),
+			fmt.Sprintf("pad=%d:%d:(ow-iw)/2:(oh-ih)/2", opts.Width, opts.Height),
+			"setsar=1",
+		)
+	case opts.Width > 0 && opts.Height > 0:
+		filters = append(filters, fmt.Sprintf("scale=%d:%d", opts.Width, opts.Height), "setsar=1")
+	}
+	if opts.FPS > 0 {
+		filters = append(filters, fmt.Sprintf("fps=%s", formatFloat(opts.FPS)))
+	}
+	filters = append(filters, opts.Filters...)
+	filters = append(filters, fmt.Sprintf("format=%s", pixFmt))
+
+	args := []string{"-i", input, "-vf", strings.Join(filters, ","), "-c:v", codec, "-crf", strconv.Itoa(crf)}
+	if opts.TimeScale > 0 {
+		args = append(args, "-video_track_timescale", strconv.Itoa(opts.TimeScale))
+	}
+	if opts.Duration > 0 {
+		args = append(args, "-t", seconds(opts.Duration))
+	}
+	switch {
+	case opts.NoAudio:
+		args = append(args, "-an")
+	case opts.SampleRate > 0:
+		args = append(args, "-c:a", "aac", "-ar", strconv.Itoa(opts.SampleRate), "-ac", "2")
+	}
+	args = append(args, output)
+	if err := f.run(ctx, "transcode", f.duration(ctx, input), args...); err != nil {
+		return fmt.Errorf("ffmpeg: couldn't transcode video: %w", err)
+	}
+	return nil
+}
+
+// Crossfade joins two videos fading from the end of the first one to the
+// start of the second one during the given duration.
+func (f *FFmpeg) Crossfade(ctx context.Context, first, second, output string, d time.Duration) error {
+	a, err := f.Probe(ctx, first)
+	if err != nil {
+		return err
+	}
+	b, err := f.Probe(ctx, second)
+	if err != nil {
+		return err
+	}
+	if d <= 0 || d >= a.Duration || d >= b.Duration {
+		return fmt.Errorf("ffmpeg: invalid crossfade duration %s", d)
+	}
+	offset := a.Duration - d
+	filter := fmt.Sprintf("[0:v][1:v]xfade=transition=fade:duration=%s:offset=%s,format=yuv420p[v]", seconds(d), seconds(offset))
+	args := []string{"-i", first, "-i", second}
+	maps := []string{"-map", "[v]"}
+	if a.HasAudio && b.HasAudio {
+		filter += fmt.Sprintf(";[0:a][1:a]acrossfade=d=%s[a]", seconds(d))
+		maps = append(maps, "-map", "[a]")
+	}
+	args = append(args, "-filter_complex", filter)
+	args = append(args, maps...)
+	args = append(args, "-c:v", "libx264", "-crf", "18", output)
+	if err := f.run(ctx, "crossfade", a.Duration+b.Duration-d, args...); err != nil {
+		return fmt.Errorf("ffmpeg: couldn't crossfade videos: %w", err)
+	}
+	return nil
+}
+
+// duration returns the duration of the input or 0 if it can't be probed.
+// It is only used to report progress.
+func (f *FFmpeg) duration(ctx context.Context, input string) time.Duration {
+	if f.progress == nil {
+		return 0
+	}
+	info, err := f.Probe(ctx, input)
+	if err != nil {
+		return 0
+	}
+	return info.Duration
+}
+
+// seconds formats a duration as seconds for ffmpeg arguments.
+func seconds(d time.Duration) string {
+	return formatFloat(d.Seconds())
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// LogProgress logs the progress of an operation, it can be used as the
+// progress callback.
+func LogProgress(p Progress) {
+	switch {
+	case p.Done:
+		log.Printf("ffmpeg: %s done\n", p.Operation)
+	case p.Ratio() >= 0:
+		log.Printf("ffmpeg: %s %.0f%%\n", p.Operation, p.Ratio()*100)
+	default:
+		log.Printf("ffmpeg: %s %s\n", p.Operation, p.Time)
+	}
+}
@@ -0,0 +1,491 @@
+package ffmpeg
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const probeJSON = `{
+	"streams": [
+		{
+			"codec_type": "video",
+			"codec_name": "h264",
+			"width": 1280,
+			"height": 768,
+			"pix_fmt": "yuv420p",
+			"r_frame_rate": "24/1",
+			"avg_frame_rate": "24000/1001",
+			"time_base": "1/12288",
+			"nb_frames": "240",
+			"duration": "10.000000"
+		},
+		{
+			"codec_type": "audio",
+			"codec_name": "aac",
+			"sample_rate": "44100",
+			"channels": 2
+		}
+	],
+	"format": {
+		"duration": "10.010000"
+	}
+}`
+
+func TestProbe(t *testing.T) {
+	fake := &Fake{
+		Handler: func(name string, args []string, stdout io.Writer) error {
+			_, err := io.WriteString(stdout, probeJSON)
+			return err
+		},
+	}
+	f := New(&Config{Bin: "/opt/bin/ffmpeg", Runner: fake})
+	info, err := f.Probe(context.Background(), "input.mp4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls := fake.Calls(); len(calls) != 1 || calls[0].Name != "/opt/bin/ffprobe" {
+		t.Fatalf("unexpected calls %v", calls)
+	}
+	if info.Duration != 10010*time.Millisecond {
+		t.Errorf("unexpected duration %s", info.Duration)
+	}
+	if info.Width != 1280 || info.Height != 768 || info.Codec != "h264" || info.Frames != 240 {
+		t.Errorf("unexpected video info %+v", info)
+	}
+	if fps := fmt.Sprintf("%.3f", info.FPS); fps != "23.976" {
+		t.Errorf("unexpected fps %s", fps)
+	}
+	if !info.HasAudio || info.SampleRate != 44100 {
+		t.Errorf("unexpected audio info %+v", info)
+	}
+}
+
+func TestExtractFrame(t *testing.T) {
+	fake := &Fake{}
+	f := New(&Config{Runner: fake})
+	ctx := context.Background()
+	if err := f.ExtractFrame(ctx, "in.mp4", "out.jpg", -500*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	calls := fake.Calls()
+	if len(calls) != 1 || calls[0].Name != "ffmpeg" {
+		t.Fatalf("unexpected calls %v", calls)
+	}
+	args := strings.Join(calls[0].Args, " ")
+	if !strings.Contains(args, "-sseof -0.5 -i in.mp4 -frames:v 1") || !strings.HasSuffix(args, "out.jpg") {
+		t.Errorf("unexpected args %s", args)
+	}
+
+	if err := f.ExtractFrames(ctx, "in.mp4", "frame-%04d.png", -time.Second); err != nil {
+		t.Fatal(err)
+	}
+	calls = fake.Calls()
+	if len(calls) != 2 {
+		t.Fatalf("unexpected calls %v", calls)
+	}
+	args = strings.Join(calls[1].Args, " ")
+	if !strings.Contains(args, "-sseof -1 -i in.mp4 -vsync 0") || !strings.HasSuffix(args, "frame-%04d.png") {
+		t.Errorf("unexpected args %s", args)
+	}
+}
+
+func TestProgress(t *testing.T) {
+	fake := &Fake{
+		Handler: func(name string, args []string, stdout io.Writer) error {
+			if name == "ffprobe" {
+				_, err := io.WriteString(stdout, probeJSON)
+				return err
+			}
+			_, err := io.WriteString(stdout, "frame=10\nout_time_us=5005000\nprogress=continue\nout_time_us=10010000\nprogress=end\n")
+			return err
+		},
+	}
+	var progress []Progress
+	f := New(&Config{
+		Runner: fake,
+		Progress: func(p Progress) {
+			progress = append(progress, p)
+		},
+	})
+	if err := f.Reverse(context.Background(), "in.mp4", "out.mp4"); err != nil {
+		t.Fatal(err)
+	}
+	if len(progress) != 2 {
+		t.Fatalf("expected 2 progress updates, got %d", len(progress))
+	}
+	if r := progress[0].Ratio(); r < 0.49 || r > 0.51 {
+		t.Errorf("unexpected ratio %f", r)
+	}
+	if !progress[1].Done || progress[1].Operation != "reverse" {
+		t.Errorf("unexpected progress %+v", progress[1])
+	}
+}
+
+func TestError(t *testing.T) {
+	err := &Error{Name: "ffmpeg", Output: "\nfirst\nsecond\n\n", Err: fmt.Errorf("exit status 1")}
+	if got := err.Error(); got != "ffmpeg failed: first; second: exit status 1" {
+		t.Errorf("unexpected error %q", got)
+	}
+}
+
+func TestJoin(t *testing.T) {
+	probe := func(input string) string {
+		fps := "24/1"
+		if input == "b.mp4" {
+			fps = "30/1"
+		}
+		return fmt.Sprintf(`{"streams":[{"codec_type":"video","codec_name":"h264","width":1280,"height":768,"pix_fmt":"yuv420p","avg_frame_rate":%q,"time_base":"1/12288"}],"format":{"duration":"4"}}`, fps)
+	}
+	newFake := func() *Fake {
+		return &Fake{
+			Handler: func(name string, args []string, stdout io.Writer) error {
+				if name == "ffprobe" {
+					_, err := io.WriteString(stdout, probe(args[len(args)-1]))
+					return err
+				}
+				return nil
+			},
+		}
+	}
+	ctx := context.Background()
+	countTranscodes := func(calls []Call) int {
+		var n int
+		for _, c := range calls {
+			if c.Name == "ffmpeg" && strings.Contains(strings.Join(c.Args, " "), "-c:v libx264") {
+				n++
+			}
+		}
+		return n
+	}
+
+	// Compatible inputs are concatenated as they are
+	fake := newFake()
+	if err := New(&Config{Runner: fake}).Join(ctx, []string{"a.mp4", "a.mp4"}, "out.mp4", nil); err != nil {
+		t.Fatal(err)
+	}
+	if n := countTranscodes(fake.Calls()); n != 0 {
+		t.Errorf("expected 0 transcodes, got %d", n)
+	}
+
+	// Mismatched inputs are normalized
+	fake = newFake()
+	if err := New(&Config{Runner: fake}).Join(ctx, []string{"a.mp4", "b.mp4"}, "out.mp4", &JoinOptions{Normalize: NormalizeAuto}); err != nil {
+		t.Fatal(err)
+	}
+	calls := fake.Calls()
+	if n := countTranscodes(calls); n != 2 {
+		t.Errorf("expected 2 transcodes, got %d", n)
+	}
+	if args := strings.Join(calls[2].Args, " "); !strings.Contains(args, "fps=24") || !strings.Contains(args, "-an") {
+		t.Errorf("unexpected transcode args %s", args)
+	}
+
+	// Normalization can be disabled
+	fake = newFake()
+	if err := New(&Config{Runner: fake}).Join(ctx, []string{"a.mp4", "b.mp4"}, "out.mp4", &JoinOptions{Normalize: NormalizeNever}); err != nil {
+		t.Fatal(err)
+	}
+	if calls := fake.Calls(); len(calls) != 1 {
+		t.Errorf("expected 1 call, got %d", len(calls))
+	}
+}
+
+func TestJoinCrossfade(t *testing.T) {
+	fake := &Fake{
+		Handler: func(name string, args []string, stdout io.Writer) error {
+			if name == "ffprobe" {
+				_, err := io.WriteString(stdout, `{"streams":[{"codec_type":"video","codec_name":"h264","width":1280,"height":768,"avg_frame_rate":"24/1"}],"format":{"duration":"4"}}`)
+				return err
+			}
+			return nil
+		},
+	}
+	opts := &JoinOptions{Crossfade: 500 * time.Millisecond}
+	if err := New(&Config{Runner: fake}).Join(context.Background(), []string{"a.mp4", "b.mp4", "c.mp4"}, "out.mp4", opts); err != nil {
+		t.Fatal(err)
+	}
+	calls := fake.Calls()
+	args := strings.Join(calls[len(calls)-1].Args, " ")
+	if !strings.Contains(args, "xfade=transition=fade:duration=0.5:offset=3.5[v1]") ||
+		!strings.Contains(args, "xfade=transition=fade:duration=0.5:offset=7[v2]") {
+		t.Errorf("unexpected crossfade args %s", args)
+	}
+}
+
+func TestLoop(t *testing.T) {
+	fake := &Fake{
+		Handler: func(name string, args []string, stdout io.Writer) error {
+			if name == "ffprobe" {
+				_, err := io.WriteString(stdout, `{"streams":[{"codec_type":"video","codec_name":"h264","width":1280,"height":768,"avg_frame_rate":"24/1","nb_frames":"48"},{"codec_type":"audio","codec_name":"aac","sample_rate":"44100","channels":2}],"format":{"duration":"2"}}`)
+				return err
+			}
+			return nil
+		},
+	}
+	f := New(&Config{Runner: fake})
+	ctx := context.Background()
+	last := func() string {
+		calls := fake.Calls()
+		return strings.Join(calls[len(calls)-1].Args, " ")
+	}
+
+	if err := f.PingPong(ctx, "in.mp4", "out.mp4", true); err != nil {
+		t.Fatal(err)
+	}
+	if args := last(); !strings.Contains(args, "reverse,trim=start_frame=1:end_frame=47") || !strings.Contains(args, "apad=whole_dur=3.9166") {
+		t.Errorf("unexpected pingpong args %s", args)
+	}
+
+	if err := f.CrossfadeLoop(ctx, "in.mp4", "out.mp4", 500*time.Millisecond, false); err != nil {
+		t.Fatal(err)
+	}
+	if args := last(); !strings.Contains(args, "xfade=transition=fade:duration=0.5:offset=1,") || strings.Contains(args, "acrossfade") {
+		t.Errorf("unexpected crossfade loop args %s", args)
+	}
+	if err := f.CrossfadeLoop(ctx, "in.mp4", "out.mp4", time.Second, false); err == nil {
+		t.Error("expected error for crossfade longer than half the video")
+	}
+
+	if err := f.Repeat(ctx, "in.mp4", "out.mp4", 3, 5*time.Second, false); err != nil {
+		t.Fatal(err)
+	}
+	if args := last(); !strings.Contains(args, "-stream_loop 2 -i in.mp4 -t 5 -an -c copy out.mp4") {
+		t.Errorf("unexpected repeat args %s", args)
+	}
+}
+
+func TestExport(t *testing.T) {
+	fake := &Fake{}
+	f := New(&Config{Runner: fake})
+	ctx := context.Background()
+
+	if err := f.Export(ctx, "in.mp4", "out.gif", &ExportOptions{Format: FormatGIF, FPS: 12, Width: 480}); err != nil {
+		t.Fatal(err)
+	}
+	calls := fake.Calls()
+	args := strings.Join(calls[len(calls)-1].Args, " ")
+	if !strings.Contains(args, "-filter_complex fps=12,scale=480:-2:flags=lanczos,split[s0][s1];[s0]palettegen") || !strings.HasSuffix(args, "-loop 0 out.gif") {
+		t.Errorf("unexpected gif args %s", args)
+	}
+
+	dir := filepath.Join(t.TempDir(), "frames")
+	if err := f.Export(ctx, "in.mp4", dir, &ExportOptions{Format: FormatFrames}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(dir); err != nil {
+		t.Errorf("expected frames dir to be created: %v", err)
+	}
+	calls = fake.Calls()
+	args = strings.Join(calls[len(calls)-1].Args, " ")
+	if !strings.HasSuffix(args, filepath.Join(dir, "frame-%05d.png")) {
+		t.Errorf("unexpected frames args %s", args)
+	}
+
+	if err := f.Export(ctx, "in.mp4", "out.avi", &ExportOptions{Format: "avi"}); err == nil {
+		t.Error("expected error for unknown format")
+	}
+}
+
+func TestEdit(t *testing.T) {
+	ops, err := ParseOps("trim=0:4,speed=0.25,scale=1080x1920,mute")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ops) != 4 || ops[0].End != 4*time.Second || ops[1].Speed != 0.25 || ops[2].Width != 1080 || ops[2].Height != 1920 {
+		t.Fatalf("unexpected ops %+v", ops)
+	}
+	for _, spec := range []string{"trim=4:2", "speed=0", "scale=1080", "reverse=1", "blur=2", ""} {
+		if _, err := ParseOps(spec); err == nil {
+			t.Errorf("expected error for %q", spec)
+		}
+	}
+
+	fake := &Fake{
+		Handler: func(name string, args []string, stdout io.Writer) error {
+			if name == "ffprobe" {
+				_, err := io.WriteString(stdout, probeJSON)
+				return err
+			}
+			return nil
+		},
+	}
+	f := New(&Config{Runner: fake})
+	if err := f.Edit(context.Background(), "in.mp4", "out.mp4", ops); err != nil {
+		t.Fatal(err)
+	}
+	calls := fake.Calls()
+	args := strings.Join(calls[len(calls)-1].Args, " ")
+	if !strings.Contains(args, "trim=start=0:end=4,setpts=PTS-STARTPTS,setpts=PTS/0.25,minterpolate=fps=") ||
+		!strings.Contains(args, "pad=1080:1920:(ow-iw)/2:(oh-ih)/2") || !strings.Contains(args, "-an out.mp4") {
+		t.Errorf("unexpected edit args %s", args)
+	}
+
+	if got := strings.Join(atempo(0.25), ","); got != "atempo=0.5,atempo=0.5" {
+		t.Errorf("unexpected atempo %s", got)
+	}
+}
+
+func TestAddAudio(t *testing.T) {
+	fake := &Fake{
+		Handler: func(name string, args []string, stdout io.Writer) error {
+			if name != "ffprobe" {
+				return nil
+			}
+			js := `{"streams":[{"codec_type":"video","codec_name":"h264","width":1280,"height":768,"avg_frame_rate":"24/1"}],"format":{"duration":"10"}}`
+			if args[len(args)-1] == "track.mp3" {
+				js = `{"streams":[{"codec_type":"audio","codec_name":"mp3","sample_rate":"44100","channels":2}],"format":{"duration":"4"}}`
+			}
+			_, err := io.WriteString(stdout, js)
+			return err
+		},
+	}
+	f := New(&Config{Runner: fake})
+	opts := &AudioOptions{FadeIn: time.Second, FadeOut: 2 * time.Second}
+	if err := f.AddAudio(context.Background(), "in.mp4", "track.mp3", "out.mp4", opts); err != nil {
+		t.Fatal(err)
+	}
+	calls := fake.Calls()
+	args := strings.Join(calls[len(calls)-1].Args, " ")
+	if !strings.Contains(args, "-i in.mp4 -stream_loop -1 -i track.mp3") ||
+		!strings.Contains(args, "atrim=end=10,asetpts=PTS-STARTPTS,afade=t=in:st=0:d=1,afade=t=out:st=8:d=2") ||
+		!strings.HasSuffix(args, "-c:v copy -c:a aac -t 10 out.mp4") {
+		t.Errorf("unexpected args %s", args)
+	}
+
+	opts.NoLoop = true
+	if err := f.AddAudio(context.Background(), "in.mp4", "track.mp3", "out.mp4", opts); err != nil {
+		t.Fatal(err)
+	}
+	calls = fake.Calls()
+	args = strings.Join(calls[len(calls)-1].Args, " ")
+	if strings.Contains(args, "-stream_loop") || !strings.Contains(args, "afade=t=out:st=2:d=2") {
+		t.Errorf("unexpected no loop args %s", args)
+	}
+}
+
+func TestJoinTransitions(t *testing.T) {
+	fake := &Fake{
+		Handler: func(name string, args []string, stdout io.Writer) error {
+			if name == "ffprobe" {
+				_, err := io.WriteString(stdout, `{"streams":[{"codec_type":"video","codec_name":"h264","width":1280,"height":768,"avg_frame_rate":"24/1"}],"format":{"duration":"4"}}`)
+				return err
+			}
+			return nil
+		},
+	}
+	f := New(&Config{Runner: fake})
+	inputs := []string{"a.mp4", "b.mp4", "c.mp4"}
+	opts := &JoinOptions{Transitions: []Transition{
+		{Name: TransitionCut},
+		{Name: "wipeleft", Duration: time.Second},
+	}}
+	if err := f.Join(context.Background(), inputs, "out.mp4", opts); err != nil {
+		t.Fatal(err)
+	}
+	calls := fake.Calls()
+	args := strings.Join(calls[len(calls)-1].Args, " ")
+	if !strings.Contains(args, "[0:v][1:v]concat=n=2:v=1:a=0[v1]") ||
+		!strings.Contains(args, "[v1][2:v]xfade=transition=wipeleft:duration=1:offset=7[v2]") {
+		t.Errorf("unexpected transition args %s", args)
+	}
+
+	opts.Transitions = opts.Transitions[:1]
+	if err := f.Join(context.Background(), inputs, "out.mp4", opts); err == nil {
+		t.Error("expected error for wrong number of transitions")
+	}
+
+	opts.Transitions = []Transition{{Name: TransitionCut}, {Name: "fade:duration=9[x]", Duration: time.Second}}
+	if err := f.Join(context.Background(), inputs, "out.mp4", opts); err == nil {
+		t.Error("expected error for unknown transition")
+	}
+	for name, want := range map[string]bool{"": true, "cut": true, "fade": true, "dissolve": true, "circleopen": true, "wipe": false, "Fade": false, "fade;": false} {
+		if got := ValidTransition(name); got != want {
+			t.Errorf("%q: got %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestTitle(t *testing.T) {
+	fake := &Fake{
+		Handler: func(name string, args []string, stdout io.Writer) error {
+			if name == "ffprobe" {
+				_, err := io.WriteString(stdout, `{"streams":[{"codec_type":"video","codec_name":"h264","width":1280,"height":768,"avg_frame_rate":"24/1"}],"format":{"duration":"5"}}`)
+				return err
+			}
+			return nil
+		},
+	}
+	f := New(&Config{Runner: fake})
+	opts := &TitleOptions{Text: "Day 1: it's on", Duration: 2 * time.Second}
+	if err := f.Title(context.Background(), "in.mp4", "out.mp4", opts); err != nil {
+		t.Fatal(err)
+	}
+	calls := fake.Calls()
+	args := strings.Join(calls[len(calls)-1].Args, " ")
+	if !strings.Contains(args, `drawtext=text=Day 1\\: it\\\'s on:expansion=none`) ||
+		!strings.Contains(args, "enable='lt(t,2)'") ||
+		!strings.Contains(args, "if(lt(t,0.5),t/0.5,if(lt(t,1.5),1,(2-t)/0.5))") {
+		t.Errorf("unexpected title args %s", args)
+	}
+	if err := f.Title(context.Background(), "in.mp4", "out.mp4", &TitleOptions{}); err == nil {
+		t.Error("expected error for empty title")
+	}
+}
+
+func TestBridgeLoop(t *testing.T) {
+	fake := &Fake{
+		Handler: func(name string, args []string, stdout io.Writer) error {
+			if name != "ffprobe" {
+				return nil
+			}
+			// The clip has audio and the bridge has a different format
+			probe := `{"streams":[{"codec_type":"video","codec_name":"h264","width":1280,"height":768,"avg_frame_rate":"24/1"},{"codec_type":"audio","codec_name":"aac","sample_rate":"44100","channels":2}],"format":{"duration":"4"}}`
+			if args[len(args)-1] == "bridge.mp4" {
+				probe = `{"streams":[{"codec_type":"video","codec_name":"h264","width":1280,"height":720,"avg_frame_rate":"25/1"}],"format":{"duration":"2.04"}}`
+			}
+			_, err := io.WriteString(stdout, probe)
+			return err
+		},
+	}
+	f := New(&Config{Runner: fake})
+	ctx := context.Background()
+	if err := f.BridgeLoop(ctx, "clip.mp4", "bridge.mp4", "out.mp4", 500*time.Millisecond, true); err != nil {
+		t.Fatal(err)
+	}
+	calls := fake.Calls()
+	args := strings.Join(calls[len(calls)-1].Args, " ")
+	// The bridge drops its last frame and is scaled to the clip format, the
+	// last 0.5s of the clip fade into the bridge and the loop is 5.5s long
+	for _, want := range []string{
+		"-i clip.mp4 -i bridge.mp4",
+		"[0:v]scale=1280:768,setsar=1,fps=24[clip]",
+		"[1:v]trim=end_frame=50,setpts=PTS-STARTPTS,scale=1280:768,setsar=1,fps=24[bridge]",
+		"[clip][bridge]xfade=transition=fade:duration=0.5:offset=3.5,",
+		"[0:a]apad=whole_dur=5.5[a]",
+		"-map [v] -map [a]",
+	} {
+		if !strings.Contains(args, want) {
+			t.Errorf("missing %q in bridge loop args %s", want, args)
+		}
+	}
+
+	if err := f.BridgeLoop(ctx, "clip.mp4", "bridge.mp4", "out.mp4", 500*time.Millisecond, false); err != nil {
+		t.Fatal(err)
+	}
+	calls = fake.Calls()
+	if args := strings.Join(calls[len(calls)-1].Args, " "); strings.Contains(args, "[0:a]") || strings.Contains(args, "-map [a]") {
+		t.Errorf("unexpected audio in bridge loop args %s", args)
+	}
+	if err := f.BridgeLoop(ctx, "clip.mp4", "bridge.mp4", "out.mp4", 0, false); err == nil {
+		t.Error("expected error without crossfade")
+	}
+	if err := f.BridgeLoop(ctx, "clip.mp4", "bridge.mp4", "out.mp4", 3*time.Second, false); err == nil {
+		t.Error("expected error with a crossfade longer than the bridge")
+	}
+}
@@ -0,0 +1,269 @@
+package ffmpeg
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Normalization modes used when joining videos.
+const (
+	// NormalizeAuto normalizes the segments only if their formats differ.
+	NormalizeAuto = "auto"
+	// NormalizeAlways always encodes the segments again.
+	NormalizeAlways = "always"
+	// NormalizeNever joins the segments without encoding them again.
+	NormalizeNever = "never"
+)
+
+// Transition names with a special meaning, any other name is passed to the
+// xfade filter (e.g. "wipeleft", "dissolve" or "circleopen").
+const (
+	// TransitionCut joins the segments without a transition.
+	TransitionCut = "cut"
+	// TransitionFade fades from one segment to the next one.
+	TransitionFade = "fade"
+)
+
+// xfadeTransitions are the transitions of the xfade filter.
+var xfadeTransitions = map[string]bool{}
+
+func init() {
+	for _, name := range strings.Fields(`fade wipeleft wiperight wipeup wipedown
+		slideleft slideright slideup slidedown circlecrop rectcrop distance
+		fadeblack fadewhite radial smoothleft smoothright smoothup smoothdown
+		circleopen circleclose vertopen vertclose horzopen horzclose dissolve
+		pixelize diagtl diagtr diagbl diagbr hlslice hrslice vuslice vdslice
+		hblur fadegrays wipetl wipetr wipebl wipebr squeezeh squeezev zoomin
+		fadefast fadeslow hlwind hrwind vuwind vdwind coverleft coverright
+		coverup coverdown revealleft revealright revealup revealdown`) {
+		xfadeTransitions[name] = true
+	}
+}
+
+// ValidTransition returns true if the name is TransitionCut or a transition
+// of the xfade filter. Empty names are valid and default to TransitionFade.
+func ValidTransition(name string) bool {
+	return name == "" || name == TransitionCut || xfadeTransitions[name]
+}
+
+// Transition between two consecutive segments.
+type Transition struct {
+	// Name of the transition, defaults to TransitionFade.
+	Name string
+	// Duration of the transition, zero means a cut.
+	Duration time.Duration
+}
+
+func (t Transition) cut() bool {
+	return t.Name == TransitionCut || t.Duration <= 0
+}
+
+type JoinOptions struct {
+	// Normalize is the normalization mode, defaults to NormalizeAuto.
+	Normalize string
+	// Crossfade is the duration of the fade between segments (optional).
+	// Segments are always normalized when crossfading.
+	Crossfade time.Duration
+	// Transitions between each pair of segments (optional). If set, it
+	// must have one element less than the inputs and it overrides Crossfade.
+	Transitions []Transition
+}
+
+// Join concatenates the inputs into the output. Segments with different
+// resolution, frame rate, codec or time base would produce a broken file if
+// they were concatenated as they are, so they are normalized to the format
+// of the first input before joining them.
+func (f *FFmpeg) Join(ctx context.Context, inputs []string, output string, opts *JoinOptions) error {
+	if opts == nil {
+		opts = &JoinOptions{}
+	}
+	normalize := opts.Normalize
+	if normalize == "" {
+		normalize = NormalizeAuto
+	}
+	switch normalize {
+	case NormalizeAuto, NormalizeAlways, NormalizeNever:
+	default:
+		return fmt.Errorf("ffmpeg: unknown normalize mode %q", normalize)
+	}
+	if len(inputs) == 0 {
+		return fmt.Errorf("ffmpeg: no inputs to join")
+	}
+	transitions := opts.Transitions
+	if transitions == nil && opts.Crossfade > 0 {
+		for i := 1; i < len(inputs); i++ {
+			transitions = append(transitions, Transition{Name: TransitionFade, Duration: opts.Crossfade})
+		}
+	}
+	if transitions != nil && len(transitions) != len(inputs)-1 {
+		return fmt.Errorf("ffmpeg: %d transitions for %d inputs", len(transitions), len(inputs))
+	}
+	for _, t := range transitions {
+		if !ValidTransition(t.Name) {
+			return fmt.Errorf("ffmpeg: unknown transition %q", t.Name)
+		}
+	}
+	crossfade := false
+	for _, t := range transitions {
+		if !t.cut() {
+			crossfade = true
+		}
+	}
+	if normalize == NormalizeNever && !crossfade {
+		return f.Concat(ctx, inputs, output)
+	}
+
+	infos := make([]*Info, 0, len(inputs))
+	for _, in := range inputs {
+		info, err := f.Probe(ctx, in)
+		if err != nil {
+			return err
+		}
+		infos = append(infos, info)
+	}
+	if normalize == NormalizeAuto && !crossfade && compatible(infos) {
+		return f.Concat(ctx, inputs, output)
+	}
+
+	dir, err := os.MkdirTemp(f.tempDir, "vidai-join-*")
+	if err != nil {
+		return fmt.Errorf("ffmpeg: couldn't create temp dir: %w", err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	transcodeOpts := normalizeOptions(infos)
+	var segments []string
+	for i, in := range inputs {
+		segment := filepath.Join(dir, fmt.Sprintf("%03d.mp4", i))
+		if err := f.Transcode(ctx, in, segment, transcodeOpts); err != nil {
+			return fmt.Errorf("ffmpeg: couldn't normalize %s: %w", in, err)
+		}
+		segments = append(segments, segment)
+	}
+	if crossfade {
+		return f.crossfadeAll(ctx, segments, infos, output, transitions, !transcodeOpts.NoAudio)
+	}
+	return f.Concat(ctx, segments, output)
+}
+
+// crossfadeAll joins the segments chaining a transition between each pair of
+// them. Cuts are joined with the concat filter.
+func (f *FFmpeg) crossfadeAll(ctx context.Context, segments []string, infos []*Info, output string, transitions []Transition, audio bool) error {
+	var args []string
+	for _, s := range segments {
+		args = append(args, "-i", s)
+	}
+	var filters []string
+	prevV, prevA := "[0:v]", "[0:a]"
+	total := infos[0].Duration
+	for i := 1; i < len(segments); i++ {
+		t := transitions[i-1]
+		v, a := fmt.Sprintf("[v%d]", i), fmt.Sprintf("[a%d]", i)
+		if t.cut() {
+			total += infos[i].Duration
+			filters = append(filters, fmt.Sprintf("%s[%d:v]concat=n=2:v=1:a=0%s", prevV, i, v))
+			if audio {
+				filters = append(filters, fmt.Sprintf("%s[%d:a]concat=n=2:v=0:a=1%s", prevA, i, a))
+			}
+			prevV, prevA = v, a
+			continue
+		}
+		d := t.Duration
+		if d >= infos[i].Duration || d >= infos[i-1].Duration {
+			return fmt.Errorf("ffmpeg: crossfade %s is longer than segment %d", d, i)
+		}
+		name := t.Name
+		if name == "" {
+			name = TransitionFade
+		}
+		offset := total - d
+		total += infos[i].Duration - d
+		filters = append(filters, fmt.Sprintf("%s[%d:v]xfade=transition=%s:duration=%s:offset=%s%s", prevV, i, name, seconds(d), seconds(offset), v))
+		if audio {
+			filters = append(filters, fmt.Sprintf("%s[%d:a]acrossfade=d=%s%s", prevA, i, seconds(d), a))
+		}
+		prevV, prevA = v, a
+	}
+	filters = append(filters, fmt.Sprintf("%sformat=yuv420p[vout]", prevV))
+	args = append(args, "-filter_complex", strings.Join(filters, ";"), "-map", "[vout]")
+	if audio {
+		args = append(args, "-map", prevA)
+	}
+	args = append(args, "-c:v", "libx264", "-crf", "18", output)
+	if err := f.run(ctx, "crossfade", total, args...); err != nil {
+		return fmt.Errorf("ffmpeg: couldn't crossfade videos: %w", err)
+	}
+	return nil
+}
+
+// compatible returns true if the streams of all the videos can be
+// concatenated without encoding them again.
+func compatible(infos []*Info) bool {
+	first := infos[0]
+	for _, info := range infos[1:] {
+		if info.Codec != first.Codec ||
+			info.Width != first.Width ||
+			info.Height != first.Height ||
+			info.PixFmt != first.PixFmt ||
+			info.TimeBase != first.TimeBase ||
+			math.Abs(info.FPS-first.FPS) > 0.01 {
+			return false
+		}
+		if info.HasAudio != first.HasAudio ||
+			info.AudioCodec != first.AudioCodec ||
+			info.SampleRate != first.SampleRate ||
+			info.Channels != first.Channels {
+			return false
+		}
+	}
+	return true
+}
+
+// normalizeOptions returns the transcode options to convert all the videos
+// to the format of the first one. Audio is kept only if all the videos have
+// an audio stream.
+func normalizeOptions(infos []*Info) *TranscodeOptions {
+	first := infos[0]
+	opts := &TranscodeOptions{
+		Width:     first.Width,
+		Height:    first.Height,
+		Pad:       true,
+		FPS:       math.Round(first.FPS*1000) / 1000,
+		TimeScale: timeScale(first.TimeBase),
+	}
+	audio := true
+	for _, info := range infos {
+		if !info.HasAudio {
+			audio = false
+			break
+		}
+	}
+	if audio {
+		opts.SampleRate = first.SampleRate
+		if opts.SampleRate == 0 {
+			opts.SampleRate = 44100
+		}
+	} else {
+		opts.NoAudio = true
+	}
+	return opts
+}
+
+// timeScale returns the denominator of a time base in the form "1/12288".
+func timeScale(timeBase string) int {
+	num, den, ok := strings.Cut(timeBase, "/")
+	if !ok || num != "1" {
+		return 0
+	}
+	n, err := strconv.Atoi(den)
+	if err != nil {
+		return 0
+	}
+	return n
+}
@@ -0,0 +1,148 @@
+package ffmpeg
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+// PingPong plays the input forward and then backwards.
+// The first and last frames of the backwards part are dropped because they
+// are the same as the frames at the turnaround, so the output can be looped
+// without showing any frame twice.
+// If audio is set, the forward audio is kept and the backwards part is silent.
+func (f *FFmpeg) PingPong(ctx context.Context, input, output string, audio bool) error {
+	info, err := f.Probe(ctx, input)
+	if err != nil {
+		return err
+	}
+	frames := info.Frames
+	if frames == 0 {
+		frames = int(math.Round(info.Duration.Seconds() * info.FPS))
+	}
+	if frames < 3 {
+		return fmt.Errorf("ffmpeg: %s is too short to loop", input)
+	}
+	total := info.Duration
+	if info.FPS > 0 {
+		total = time.Duration(float64(2*frames-2) / info.FPS * float64(time.Second))
+	}
+
+	filter := fmt.Sprintf("[0:v]split[fw][rv];[rv]reverse,trim=start_frame=1:end_frame=%d,setpts=PTS-STARTPTS[bw];[fw][bw]concat=n=2:v=1:a=0,format=yuv420p[v]", frames-1)
+	maps := []string{"-map", "[v]"}
+	if audio && info.HasAudio {
+		filter += fmt.Sprintf(";[0:a]apad=whole_dur=%s[a]", seconds(total))
+		maps = append(maps, "-map", "[a]")
+	}
+	args := []string{"-i", input, "-filter_complex", filter}
+	args = append(args, maps...)
+	args = append(args, "-c:v", "libx264", "-crf", "18", output)
+	if err := f.run(ctx, "pingpong", total, args...); err != nil {
+		return fmt.Errorf("ffmpeg: couldn't create pingpong loop: %w", err)
+	}
+	return nil
+}
+
+// CrossfadeLoop fades the end of the input into its beginning, so the output
+// can be looped without a visible cut. The output is shorter than the input
+// by the crossfade duration.
+// If audio is set, the audio is crossfaded in the same way.
+func (f *FFmpeg) CrossfadeLoop(ctx context.Context, input, output string, d time.Duration, audio bool) error {
+	info, err := f.Probe(ctx, input)
+	if err != nil {
+		return err
+	}
+	if d <= 0 || 2*d >= info.Duration {
+		return fmt.Errorf("ffmpeg: invalid crossfade duration %s for %s video", d, info.Duration)
+	}
+	offset := info.Duration - 2*d
+
+	filter := fmt.Sprintf("[0:v]split[body][head];[body]trim=start=%s,setpts=PTS-STARTPTS[b];[head]trim=end=%s,setpts=PTS-STARTPTS[h];[b][h]xfade=transition=fade:duration=%s:offset=%s,format=yuv420p[v]",
+		seconds(d), seconds(d), seconds(d), seconds(offset))
+	maps := []string{"-map", "[v]"}
+	if audio && info.HasAudio {
+		filter += fmt.Sprintf(";[0:a]asplit[abody][ahead];[abody]atrim=start=%s,asetpts=PTS-STARTPTS[ab];[ahead]atrim=end=%s,asetpts=PTS-STARTPTS[ah];[ab][ah]acrossfade=d=%s[a]",
+			seconds(d), seconds(d), seconds(d))
+		maps = append(maps, "-map", "[a]")
+	}
+	args := []string{"-i", input, "-filter_complex", filter}
+	args = append(args, maps...)
+	args = append(args, "-c:v", "libx264", "-crf", "18", output)
+	if err := f.run(ctx, "crossfade loop", info.Duration-d, args...); err != nil {
+		return fmt.Errorf("ffmpeg: couldn't create crossfade loop: %w", err)
+	}
+	return nil
+}
+
+// BridgeLoop joins a clip with a bridge that ends with the first frame of
+// the clip, so the output can be looped without a visible cut.
+// The last frame of the bridge is dropped because it is the same as the
+// first frame of the clip, and the bridge is scaled to the format of the clip.
+// The end of the clip fades into the beginning of the bridge, which hides
+// the seam between them.
+// If audio is set, the audio of the clip is kept and the bridge is silent.
+func (f *FFmpeg) BridgeLoop(ctx context.Context, clip, bridge, output string, d time.Duration, audio bool) error {
+	c, err := f.Probe(ctx, clip)
+	if err != nil {
+		return err
+	}
+	b, err := f.Probe(ctx, bridge)
+	if err != nil {
+		return err
+	}
+	if c.Width <= 0 || c.Height <= 0 || c.FPS <= 0 || b.FPS <= 0 {
+		return fmt.Errorf("ffmpeg: couldn't get the video format of %s and %s", clip, bridge)
+	}
+	frames := b.Frames
+	if frames == 0 {
+		frames = int(math.Round(b.Duration.Seconds() * b.FPS))
+	}
+	if frames < 2 {
+		return fmt.Errorf("ffmpeg: %s is too short to bridge", bridge)
+	}
+	bridgeDuration := time.Duration(float64(frames-1) / b.FPS * float64(time.Second))
+	if d <= 0 || d >= c.Duration || d >= bridgeDuration {
+		return fmt.Errorf("ffmpeg: invalid crossfade duration %s for %s clip and %s bridge", d, c.Duration, bridgeDuration)
+	}
+	total := c.Duration + bridgeDuration - d
+	format := fmt.Sprintf("scale=%d:%d,setsar=1,fps=%s", c.Width, c.Height, formatFloat(c.FPS))
+
+	filter := fmt.Sprintf("[0:v]%s[clip];[1:v]trim=end_frame=%d,setpts=PTS-STARTPTS,%s[bridge];[clip][bridge]xfade=transition=fade:duration=%s:offset=%s,format=yuv420p[v]",
+		format, frames-1, format, seconds(d), seconds(c.Duration-d))
+	maps := []string{"-map", "[v]"}
+	if audio && c.HasAudio {
+		filter += fmt.Sprintf(";[0:a]apad=whole_dur=%s[a]", seconds(total))
+		maps = append(maps, "-map", "[a]")
+	}
+	args := []string{"-i", clip, "-i", bridge, "-filter_complex", filter}
+	args = append(args, maps...)
+	args = append(args, "-c:v", "libx264", "-crf", "18", output)
+	if err := f.run(ctx, "bridge loop", total, args...); err != nil {
+		return fmt.Errorf("ffmpeg: couldn't create bridge loop: %w", err)
+	}
+	return nil
+}
+
+// Repeat plays the input n times without encoding it again.
+// If limit is set, the output is cut at that duration.
+func (f *FFmpeg) Repeat(ctx context.Context, input, output string, n int, limit time.Duration, audio bool) error {
+	if n < 1 {
+		return fmt.Errorf("ffmpeg: invalid repeat count %d", n)
+	}
+	args := []string{"-stream_loop", strconv.Itoa(n - 1), "-i", input}
+	total := time.Duration(n) * f.duration(ctx, input)
+	if limit > 0 {
+		args = append(args, "-t", seconds(limit))
+		total = limit
+	}
+	if !audio {
+		args = append(args, "-an")
+	}
+	args = append(args, "-c", "copy", output)
+	if err := f.run(ctx, "repeat", total, args...); err != nil {
+		return fmt.Errorf("ffmpeg: couldn't repeat video: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package ffmpeg
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Info is the information of a media file obtained with ffprobe.
+type Info struct {
+	Duration time.Duration
+	// Video stream
+	Codec    string
+	Width    int
+	Height   int
+	FPS      float64
+	PixFmt   string
+	TimeBase string
+	Frames   int
+	// Audio stream
+	HasAudio   bool
+	AudioCodec string
+	SampleRate int
+	Channels   int
+}
+
+type probeOutput struct {
+	Streams []struct {
+		CodecType    string `json:"codec_type"`
+		CodecName    string `json:"codec_name"`
+		Width        int    `json:"width"`
+		Height       int    `json:"height"`
+		PixFmt       string `json:"pix_fmt"`
+		RFrameRate   string `json:"r_frame_rate"`
+		AvgFrameRate string `json:"avg_frame_rate"`
+		TimeBase     string `json:"time_base"`
+		NbFrames     string `json:"nb_frames"`
+		Duration     string `json:"duration"`
+		SampleRate   string `json:"sample_rate"`
+		Channels     int    `json:"channels"`
+	} `json:"streams"`
+	Format struct {
+		Duration string `json:"duration"`
+	} `json:"format"`
+}
+
+// Probe returns the information of the first video and audio streams of the
+// input.
+func (f *FFmpeg) Probe(ctx context.Context, input string) (*Info, error) {
+	args := []string{"-v", "error", "-print_format", "json", "-show_format", "-show_streams", input}
+	var buf bytes.Buffer
+	if err := f.runner.Run(ctx, f.probeBin, args, &buf); err != nil {
+		return nil, fmt.Errorf("ffmpeg: couldn't probe %s: %w", input, err)
+	}
+	info, err := parseProbe(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("ffmpeg: couldn't parse probe of %s: %w", input, err)
+	}
+	return info, nil
+}
+
+func parseProbe(b []byte) (*Info, error) {
+	var out probeOutput
+	if err := json.Unmarshal(b, &out); err != nil {
+		return nil, err
+	}
+	info := &Info{
+		Duration: parseSeconds(out.Format.Duration),
+	}
+	var video, audio bool
+	for _, s := range out.Streams {
+		switch s.CodecType {
+		case "video":
+			if video {
+				continue
+			}
+			video = true
+			info.Codec = s.CodecName
+			info.Width = s.Width
+			info.Height = s.Height
+			info.PixFmt = s.PixFmt
+			info.TimeBase = s.TimeBase
+			info.FPS = parseRate(s.AvgFrameRate)
+			if info.FPS == 0 {
+				info.FPS = parseRate(s.RFrameRate)
+			}
+			info.Frames, _ = strconv.Atoi(s.NbFrames)
+			if info.Duration == 0 {
+				info.Duration = parseSeconds(s.Duration)
+			}
+		case "audio":
+			if audio {
+				continue
+			}
+			audio = true
+			info.HasAudio = true
+			info.AudioCodec = s.CodecName
+			info.SampleRate, _ = strconv.Atoi(s.SampleRate)
+			info.Channels = s.Channels
+		}
+	}
+	if !video && !audio {
+		return nil, fmt.Errorf("no streams found")
+	}
+	return info, nil
+}
+
+// parseRate parses a frame rate in the form "24000/1001".
+func parseRate(v string) float64 {
+	num, den, ok := strings.Cut(v, "/")
+	if !ok {
+		f, _ := strconv.ParseFloat(v, 64)
+		return f
+	}
+	n, err := strconv.ParseFloat(num, 64)
+	if err != nil {
+		return 0
+	}
+	d, err := strconv.ParseFloat(den, 64)
+	if err != nil || d == 0 {
+		return 0
+	}
+	return n / d
+}
+
+func parseSeconds(v string) time.Duration {
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return 0
+	}
+	return time.Duration(f * float64(time.Second))
+}
@@ -0,0 +1,71 @@
+package ffmpeg
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+)
+
+// Runner runs external commands.
+type Runner interface {
+	// Run runs the command writing its standard output to stdout, which can
+	// be nil. The returned error should contain the standard error output.
+	Run(ctx context.Context, name string, args []string, stdout io.Writer) error
+}
+
+type execRunner struct{}
+
+func (r *execRunner) Run(ctx context.Context, name string, args []string, stdout io.Writer) error {
+	cmd := exec.CommandContext(ctx, name, args...)
+	var stderr bytes.Buffer
+	cmd.Stdout = stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return &Error{
+			Name:   name,
+			Output: stderr.String(),
+			Err:    err,
+		}
+	}
+	return nil
+}
+
+// Error is returned when a command fails.
+type Error struct {
+	Name   string
+	Output string
+	Err    error
+}
+
+func (e *Error) Error() string {
+	msg := lastLines(e.Output, 3)
+	if msg == "" {
+		return fmt.Sprintf("%s failed: %v", e.Name, e.Err)
+	}
+	return fmt.Sprintf("%s failed: %s: %v", e.Name, msg, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// lastLines returns the last n non-empty lines of the output joined by "; ".
+func lastLines(output string, n int) string {
+	var lines []string
+	for _, l := range strings.Split(output, "\n") {
+		l = strings.TrimSpace(l)
+		if l != "" {
+			lines = append(lines, l)
+		}
+	}
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "; ")
+}
@@ -0,0 +1,55 @@
+package ffmpeg
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// TitleOptions configures the text drawn by Title.
+type TitleOptions struct {
+	// Text of the title, new lines are kept.
+	Text string
+	// Duration the title is shown from the start of the video, defaults to
+	// 3 seconds. The title fades in and out.
+	Duration time.Duration
+}
+
+// Title draws a centered title over the start of the video.
+// The audio isn't encoded again.
+func (f *FFmpeg) Title(ctx context.Context, input, output string, opts *TitleOptions) error {
+	if opts == nil || opts.Text == "" {
+		return fmt.Errorf("ffmpeg: title text is required")
+	}
+	d := opts.Duration
+	if d <= 0 {
+		d = 3 * time.Second
+	}
+	total := f.duration(ctx, input)
+	if total > 0 && d > total {
+		d = total
+	}
+	fade := d / 4
+	if fade > 500*time.Millisecond {
+		fade = 500 * time.Millisecond
+	}
+	alpha := fmt.Sprintf("if(lt(t,%[1]s),t/%[1]s,if(lt(t,%[2]s),1,(%[3]s-t)/%[1]s))",
+		seconds(fade), seconds(d-fade), seconds(d))
+	filter := fmt.Sprintf("drawtext=text=%s:expansion=none:fontcolor=white:fontsize=h/12:"+
+		"x=(w-text_w)/2:y=(h-text_h)/2:box=1:boxcolor=black@0.4:boxborderw=20:"+
+		"alpha='%s':enable='lt(t,%s)',format=yuv420p",
+		escapeText(opts.Text), alpha, seconds(d))
+	args := []string{"-i", input, "-vf", filter, "-c:v", "libx264", "-crf", "18", "-c:a", "copy", output}
+	if err := f.run(ctx, "title", total, args...); err != nil {
+		return fmt.Errorf("ffmpeg: couldn't draw title: %w", err)
+	}
+	return nil
+}
+
+// escapeText escapes a drawtext value for both the filter options and the
+// filter graph.
+func escapeText(s string) string {
+	s = strings.NewReplacer(`\`, `\\`, `:`, `\:`, `'`, `\'`).Replace(s)
+	return strings.NewReplacer(`\`, `\\`, `'`, `\'`, `,`, `\,`, `;`, `\;`, `[`, `\[`, `]`, `\]`).Replace(s)
+}
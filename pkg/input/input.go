@@ -0,0 +1,148 @@
+package input
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/igolaizola/vidai/pkg/runway"
+)
+
+const assetPrefix = "asset:"
+
+// Asset returns the runway asset ID if the source has the asset prefix.
+func Asset(src string) (string, bool) {
+	if !strings.HasPrefix(src, assetPrefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(src, assetPrefix), true
+}
+
+// IsURL returns true if the source is an http(s) URL.
+func IsURL(src string) bool {
+	return strings.HasPrefix(src, "http://") || strings.HasPrefix(src, "https://")
+}
+
+// Open opens the source and returns its file name and content.
+// Sources can be local paths, http(s) URLs, "-" to read from stdin or runway
+// asset IDs with the "asset:" prefix.
+func Open(ctx context.Context, client *runway.Client, src string) (string, io.ReadCloser, error) {
+	if id, ok := Asset(src); ok {
+		_, u, _, err := client.GetAsset(ctx, id)
+		if err != nil {
+			return "", nil, fmt.Errorf("input: couldn't get asset: %w", err)
+		}
+		b, err := client.Fetch(ctx, u)
+		if err != nil {
+			return "", nil, fmt.Errorf("input: couldn't download asset: %w", err)
+		}
+		return id + extension(b), io.NopCloser(bytes.NewReader(b)), nil
+	}
+	switch {
+	case src == "-":
+		r := bufio.NewReader(os.Stdin)
+		head, _ := r.Peek(512)
+		return "stdin" + extension(head), io.NopCloser(r), nil
+	case IsURL(src):
+		b, err := client.Fetch(ctx, src)
+		if err != nil {
+			return "", nil, fmt.Errorf("input: couldn't download %s: %w", src, err)
+		}
+		var name string
+		if u, err := url.Parse(src); err == nil {
+			name = path.Base(u.Path)
+		}
+		if name == "" || name == "/" || name == "." || path.Ext(name) == "" {
+			name = strings.TrimSuffix(name, "/") + extension(b)
+			if strings.HasPrefix(name, ".") {
+				name = "input" + name
+			}
+		}
+		return name, io.NopCloser(bytes.NewReader(b)), nil
+	default:
+		f, err := os.Open(src)
+		if err != nil {
+			return "", nil, fmt.Errorf("input: couldn't open %s: %w", src, err)
+		}
+		return filepath.Base(src), f, nil
+	}
+}
+
+// ReadAll returns the file name and content of the source.
+func ReadAll(ctx context.Context, client *runway.Client, src string) (string, []byte, error) {
+	name, rc, err := Open(ctx, client, src)
+	if err != nil {
+		return "", nil, err
+	}
+	defer rc.Close()
+	b, err := io.ReadAll(rc)
+	if err != nil {
+		return "", nil, fmt.Errorf("input: couldn't read %s: %w", name, err)
+	}
+	return name, b, nil
+}
+
+// ToFile returns the path to a local file with the content of the source.
+// Sources that aren't local files are written to dir and removed when the
+// returned cleanup function is called.
+func ToFile(ctx context.Context, client *runway.Client, src, dir string) (string, func(), error) {
+	if _, ok := Asset(src); !ok && src != "-" && !IsURL(src) {
+		return src, func() {}, nil
+	}
+	name, rc, err := Open(ctx, client, src)
+	if err != nil {
+		return "", nil, err
+	}
+	defer rc.Close()
+	f, err := os.CreateTemp(dir, fmt.Sprintf("*-%s", name))
+	if err != nil {
+		return "", nil, fmt.Errorf("input: couldn't create temp file: %w", err)
+	}
+	cleanup := func() {
+		if err := os.Remove(f.Name()); err != nil && !os.IsNotExist(err) {
+			log.Println(fmt.Errorf("input: couldn't remove temp file: %w", err))
+		}
+	}
+	_, err = io.Copy(f, rc)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("input: couldn't write %s: %w", f.Name(), err)
+	}
+	return f.Name(), cleanup, nil
+}
+
+// extension returns the file extension based on the content.
+func extension(head []byte) string {
+	switch http.DetectContentType(head) {
+	case "image/jpeg":
+		return ".jpg"
+	case "image/png":
+		return ".png"
+	case "image/gif":
+		return ".gif"
+	case "image/webp":
+		return ".webp"
+	case "image/bmp":
+		return ".bmp"
+	case "video/mp4":
+		return ".mp4"
+	case "video/webm":
+		return ".webm"
+	}
+	if len(head) >= 12 && string(head[4:8]) == "ftyp" && string(head[8:12]) == "qt  " {
+		return ".mov"
+	}
+	return ""
+}
@@ -0,0 +1,138 @@
+package input
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAsset(t *testing.T) {
+	tests := []struct {
+		src    string
+		wantID string
+		wantOK bool
+	}{
+		{"asset:1234-abcd", "1234-abcd", true},
+		{"asset:", "", true},
+		{"image.jpg", "", false},
+		{"https://example.com/asset:1234", "", false},
+		{"Asset:1234", "", false},
+	}
+	for _, tt := range tests {
+		id, ok := Asset(tt.src)
+		if id != tt.wantID || ok != tt.wantOK {
+			t.Errorf("%q: got (%q, %v), want (%q, %v)", tt.src, id, ok, tt.wantID, tt.wantOK)
+		}
+	}
+}
+
+func TestIsURL(t *testing.T) {
+	tests := []struct {
+		src  string
+		want bool
+	}{
+		{"http://example.com/image.jpg", true},
+		{"https://example.com/video.mp4", true},
+		{"ftp://example.com/image.jpg", false},
+		{"image.jpg", false},
+		{"/tmp/https://image.jpg", false},
+		{"-", false},
+		{"asset:1234", false},
+	}
+	for _, tt := range tests {
+		if got := IsURL(tt.src); got != tt.want {
+			t.Errorf("%q: got %v, want %v", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestExtension(t *testing.T) {
+	tests := []struct {
+		name string
+		head string
+		want string
+	}{
+		{"jpeg", "\xff\xd8\xff\xe0\x00\x10JFIF", ".jpg"},
+		{"png", "\x89PNG\r\n\x1a\n\x00\x00\x00\x0dIHDR", ".png"},
+		{"gif", "GIF89a\x01\x00\x01\x00", ".gif"},
+		{"webp", "RIFF\x00\x00\x00\x00WEBPVP8 ", ".webp"},
+		{"bmp", "BM\x00\x00\x00\x00\x00\x00\x00\x00", ".bmp"},
+		{"mp4", "\x00\x00\x00\x18ftypmp42\x00\x00\x00\x00mp42isom", ".mp4"},
+		{"webm", "\x1a\x45\xdf\xa3\x01\x00\x00\x00", ".webm"},
+		{"mov", "\x00\x00\x00\x14ftypqt  \x00\x00\x02\x00qt  ", ".mov"},
+		{"text", "hello world", ""},
+		{"empty", "", ""},
+	}
+	for _, tt := range tests {
+		if got := extension([]byte(tt.head)); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestOpen(t *testing.T) {
+	ctx := context.Background()
+	png := "\x89PNG\r\n\x1a\n\x00\x00\x00\x0dIHDR"
+
+	// Local files keep their name and aren't copied
+	file := filepath.Join(t.TempDir(), "frame.png")
+	if err := os.WriteFile(file, []byte(png), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	name, b, err := ReadAll(ctx, nil, file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "frame.png" || string(b) != png {
+		t.Errorf("file: got (%q, %q)", name, b)
+	}
+	path, cleanup, err := ToFile(ctx, nil, file, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cleanup()
+	if path != file {
+		t.Errorf("file: got path %q, want %q", path, file)
+	}
+	if _, err := os.Stat(file); err != nil {
+		t.Errorf("file: cleanup removed the local file: %v", err)
+	}
+
+	// Stdin is named after its content
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdin := os.Stdin
+	os.Stdin = r
+	t.Cleanup(func() { os.Stdin = stdin })
+	go func() {
+		_, _ = io.WriteString(w, png)
+		_ = w.Close()
+	}()
+	tmp := t.TempDir()
+	path, cleanup, err = ToFile(ctx, nil, "-", tmp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Dir(path) != tmp || filepath.Ext(path) != ".png" {
+		t.Errorf("stdin: got path %q", path)
+	}
+	b, err = os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != png {
+		t.Errorf("stdin: got %q, want %q", b, png)
+	}
+	cleanup()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("stdin: temp file wasn't removed: %v", err)
+	}
+
+	if _, _, err := ReadAll(ctx, nil, filepath.Join(tmp, "missing.jpg")); err == nil {
+		t.Error("expected error for missing file")
+	}
+}
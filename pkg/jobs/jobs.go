@@ -0,0 +1,367 @@
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Job statuses.
+const (
+	StatusQueued    = "queued"
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+	StatusCancelled = "cancelled"
+)
+
+// errInterrupted is the error of the jobs stopped while running, by a
+// shutdown or a crash. They may have been partially generated, so they are
+// marked as failed instead of run again to avoid spending credits twice.
+const errInterrupted = "jobs: interrupted"
+
+var (
+	ErrNotFound = errors.New("jobs: job not found")
+	ErrFinished = errors.New("jobs: job already finished")
+)
+
+// Job is a request queued to be run in the background.
+type Job struct {
+	ID         string          `json:"id"`
+	Type       string          `json:"type"`
+	Status     string          `json:"status"`
+	Request    json.RawMessage `json:"request,omitempty"`
+	Output     string          `json:"output,omitempty"`
+	Error      string          `json:"error,omitempty"`
+	CreatedAt  time.Time       `json:"createdAt"`
+	StartedAt  *time.Time      `json:"startedAt,omitempty"`
+	FinishedAt *time.Time      `json:"finishedAt,omitempty"`
+}
+
+// Finished returns true if the job won't change anymore.
+func (j *Job) Finished() bool {
+	switch j.Status {
+	case StatusSucceeded, StatusFailed, StatusCancelled:
+		return true
+	default:
+		return false
+	}
+}
+
+// Runner runs a job and returns the path of its output.
+type Runner func(ctx context.Context, job *Job) (string, error)
+
+type Config struct {
+	// Path of the file where the jobs are persisted (optional, jobs are
+	// only kept in memory if empty).
+	Path string
+	// Concurrency is the number of jobs run at the same time, defaults to 1.
+	Concurrency int
+	Runner      Runner
+}
+
+// Queue runs jobs in the background with bounded concurrency.
+type Queue struct {
+	path        string
+	concurrency int
+	runner      Runner
+
+	lck     sync.Mutex
+	jobs    map[string]*Job
+	pending []string
+	cancels map[string]context.CancelFunc
+	wake    chan struct{}
+}
+
+// New creates a queue and loads the jobs persisted in the path.
+// Jobs that were running when the previous process stopped are marked as
+// failed, queued jobs are run again.
+func New(cfg *Config) (*Queue, error) {
+	if cfg.Runner == nil {
+		return nil, fmt.Errorf("jobs: runner is required")
+	}
+	concurrency := cfg.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	q := &Queue{
+		path:        cfg.Path,
+		concurrency: concurrency,
+		runner:      cfg.Runner,
+		jobs:        map[string]*Job{},
+		cancels:     map[string]context.CancelFunc{},
+		wake:        make(chan struct{}, 1),
+	}
+	if err := q.load(); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// Run launches the workers and blocks until the context is done.
+// Jobs interrupted by the context are marked as failed with errInterrupted.
+func (q *Queue) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i := 0; i < q.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			q.work(ctx)
+		}()
+	}
+	q.notify()
+	wg.Wait()
+}
+
+// Submit adds a new job to the queue.
+func (q *Queue) Submit(typ string, req json.RawMessage) (*Job, error) {
+	id, err := newID()
+	if err != nil {
+		return nil, err
+	}
+	job := &Job{
+		ID:        id,
+		Type:      typ,
+		Status:    StatusQueued,
+		Request:   req,
+		CreatedAt: time.Now().UTC(),
+	}
+	q.lck.Lock()
+	q.jobs[id] = job
+	q.pending = append(q.pending, id)
+	err = q.save()
+	cp := *job
+	q.lck.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	q.notify()
+	return &cp, nil
+}
+
+// Get returns a copy of a job.
+func (q *Queue) Get(id string) (*Job, error) {
+	q.lck.Lock()
+	defer q.lck.Unlock()
+	job, ok := q.jobs[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	cp := *job
+	return &cp, nil
+}
+
+// List returns a copy of all the jobs sorted by creation time.
+func (q *Queue) List() []*Job {
+	q.lck.Lock()
+	defer q.lck.Unlock()
+	jobs := make([]*Job, 0, len(q.jobs))
+	for _, job := range q.jobs {
+		cp := *job
+		jobs = append(jobs, &cp)
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].CreatedAt.Before(jobs[j].CreatedAt)
+	})
+	return jobs
+}
+
+// Cancel cancels a queued or running job.
+// Running jobs are marked as cancelled once their runner returns.
+func (q *Queue) Cancel(id string) (*Job, error) {
+	q.lck.Lock()
+	defer q.lck.Unlock()
+	job, ok := q.jobs[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	switch job.Status {
+	case StatusQueued:
+		for i, p := range q.pending {
+			if p == id {
+				q.pending = append(q.pending[:i], q.pending[i+1:]...)
+				break
+			}
+		}
+		now := time.Now().UTC()
+		job.Status = StatusCancelled
+		job.FinishedAt = &now
+		if err := q.save(); err != nil {
+			return nil, err
+		}
+	case StatusRunning:
+		if cancel, ok := q.cancels[id]; ok {
+			cancel()
+		}
+	default:
+		return nil, ErrFinished
+	}
+	cp := *job
+	return &cp, nil
+}
+
+func (q *Queue) work(ctx context.Context) {
+	for {
+		if ctx.Err() != nil {
+			return
+		}
+		if job, jobCtx, ok := q.next(ctx); ok {
+			q.run(ctx, jobCtx, job)
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-q.wake:
+		}
+	}
+}
+
+// next pops the next pending job and marks it as running.
+func (q *Queue) next(ctx context.Context) (Job, context.Context, bool) {
+	q.lck.Lock()
+	defer q.lck.Unlock()
+	if len(q.pending) == 0 {
+		return Job{}, nil, false
+	}
+	id := q.pending[0]
+	q.pending = q.pending[1:]
+	// Wake up another worker if there are more pending jobs
+	if len(q.pending) > 0 {
+		q.notify()
+	}
+	job := q.jobs[id]
+	now := time.Now().UTC()
+	job.Status = StatusRunning
+	job.StartedAt = &now
+	if err := q.save(); err != nil {
+		log.Println(err)
+	}
+	jobCtx, cancel := context.WithCancel(ctx)
+	q.cancels[id] = cancel
+	return *job, jobCtx, true
+}
+
+func (q *Queue) run(ctx, jobCtx context.Context, job Job) {
+	output, err := q.runner(jobCtx, &job)
+	cancelled := jobCtx.Err() != nil
+
+	q.lck.Lock()
+	defer q.lck.Unlock()
+	q.cancels[job.ID]()
+	delete(q.cancels, job.ID)
+	j := q.jobs[job.ID]
+	now := time.Now().UTC()
+	switch {
+	case cancelled && ctx.Err() != nil:
+		// The queue is stopping, see errInterrupted
+		j.Status = StatusFailed
+		j.Error = errInterrupted
+		j.FinishedAt = &now
+	case cancelled:
+		j.Status = StatusCancelled
+		j.FinishedAt = &now
+	case err != nil:
+		j.Status = StatusFailed
+		j.Error = err.Error()
+		j.FinishedAt = &now
+	default:
+		j.Status = StatusSucceeded
+		j.Output = output
+		j.FinishedAt = &now
+	}
+	if err := q.save(); err != nil {
+		log.Println(err)
+	}
+}
+
+func (q *Queue) notify() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (q *Queue) load() error {
+	if q.path == "" {
+		return nil
+	}
+	b, err := os.ReadFile(q.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("jobs: couldn't read %s: %w", q.path, err)
+	}
+	var jobs []*Job
+	if len(b) > 0 {
+		if err := json.Unmarshal(b, &jobs); err != nil {
+			return fmt.Errorf("jobs: couldn't unmarshal %s: %w", q.path, err)
+		}
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].CreatedAt.Before(jobs[j].CreatedAt)
+	})
+	for _, job := range jobs {
+		switch job.Status {
+		case StatusQueued:
+			q.pending = append(q.pending, job.ID)
+		case StatusRunning:
+			// The process stopped while the job was running, see
+			// errInterrupted
+			now := time.Now().UTC()
+			job.Status = StatusFailed
+			job.Error = errInterrupted
+			job.FinishedAt = &now
+		}
+		q.jobs[job.ID] = job
+	}
+	return nil
+}
+
+// save must be called with the lock held.
+func (q *Queue) save() error {
+	if q.path == "" {
+		return nil
+	}
+	jobs := make([]*Job, 0, len(q.jobs))
+	for _, job := range q.jobs {
+		jobs = append(jobs, job)
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].CreatedAt.Before(jobs[j].CreatedAt)
+	})
+	b, err := json.MarshalIndent(jobs, "", "  ")
+	if err != nil {
+		return fmt.Errorf("jobs: couldn't marshal jobs: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(q.path), 0755); err != nil {
+		return fmt.Errorf("jobs: couldn't create directory: %w", err)
+	}
+	// Write to a temp file and rename it to avoid corrupting the state
+	tmp := q.path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0644); err != nil {
+		return fmt.Errorf("jobs: couldn't write %s: %w", tmp, err)
+	}
+	if err := os.Rename(tmp, q.path); err != nil {
+		return fmt.Errorf("jobs: couldn't rename %s: %w", tmp, err)
+	}
+	return nil
+}
+
+func newID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("jobs: couldn't generate id: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,183 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// wait polls the job until it has the status or the timeout is reached.
+func wait(t *testing.T, q *Queue, id, status string) *Job {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		job, err := q.Get(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if job.Status == status {
+			return job
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("job %s didn't reach status %s", id, status)
+	return nil
+}
+
+func TestQueue(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jobs.json")
+	block := make(chan struct{})
+	q, err := New(&Config{
+		Path: path,
+		Runner: func(ctx context.Context, job *Job) (string, error) {
+			switch job.Type {
+			case "fail":
+				return "", errors.New("boom")
+			case "block":
+				select {
+				case <-ctx.Done():
+					return "", ctx.Err()
+				case <-block:
+				}
+			}
+			return job.ID + ".mp4", nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		q.Run(ctx)
+		close(done)
+	}()
+
+	ok, err := q.Submit("generate", json.RawMessage(`{"text":"a"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job := wait(t, q, ok.ID, StatusSucceeded); job.Output != ok.ID+".mp4" {
+		t.Errorf("unexpected output %s", job.Output)
+	}
+	failed, err := q.Submit("fail", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job := wait(t, q, failed.ID, StatusFailed); job.Error != "boom" {
+		t.Errorf("unexpected error %s", job.Error)
+	}
+
+	// A running job is cancelled and the next one is cancelled while queued
+	running, err := q.Submit("block", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	queued, err := q.Submit("generate", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wait(t, q, running.ID, StatusRunning)
+	if _, err := q.Cancel(queued.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.Cancel(running.ID); err != nil {
+		t.Fatal(err)
+	}
+	wait(t, q, running.ID, StatusCancelled)
+	wait(t, q, queued.ID, StatusCancelled)
+	if _, err := q.Cancel(ok.ID); !errors.Is(err, ErrFinished) {
+		t.Errorf("expected finished error, got %v", err)
+	}
+	if _, err := q.Get("unknown"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected not found error, got %v", err)
+	}
+
+	// Jobs interrupted by a shutdown aren't run again
+	interrupted, err := q.Submit("block", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wait(t, q, interrupted.ID, StatusRunning)
+	cancel()
+	<-done
+
+	reloaded, err := New(&Config{Path: path, Runner: q.runner})
+	if err != nil {
+		t.Fatal(err)
+	}
+	jobs := reloaded.List()
+	if len(jobs) != 5 {
+		t.Fatalf("expected 5 jobs, got %d", len(jobs))
+	}
+	want := []string{StatusSucceeded, StatusFailed, StatusCancelled, StatusCancelled, StatusFailed}
+	for i, job := range jobs {
+		if job.Status != want[i] {
+			t.Errorf("job %d: got status %s, want %s", i, job.Status, want[i])
+		}
+	}
+	if job := jobs[4]; job.Error != errInterrupted || job.FinishedAt == nil {
+		t.Errorf("unexpected interrupted job %+v", job)
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jobs.json")
+	started := make(chan string, 1)
+	runner := func(ctx context.Context, job *Job) (string, error) {
+		started <- job.ID
+		<-ctx.Done()
+		return "", ctx.Err()
+	}
+	q, err := New(&Config{Path: path, Runner: runner})
+	if err != nil {
+		t.Fatal(err)
+	}
+	running, err := q.Submit("generate", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	queued, err := q.Submit("generate", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		q.Run(ctx)
+		close(done)
+	}()
+	if id := <-started; id != running.ID {
+		t.Fatalf("expected job %s to start, got %s", running.ID, id)
+	}
+	cancel()
+	<-done
+
+	// The running job is failed and the queued one is resumed on restart
+	reloaded, err := New(&Config{Path: path, Runner: runner})
+	if err != nil {
+		t.Fatal(err)
+	}
+	job, err := reloaded.Get(running.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Status != StatusFailed || job.Error != errInterrupted {
+		t.Errorf("running job: got %s %q, want %s %q", job.Status, job.Error, StatusFailed, errInterrupted)
+	}
+	ctx, cancel = context.WithCancel(context.Background())
+	done = make(chan struct{})
+	go func() {
+		reloaded.Run(ctx)
+		close(done)
+	}()
+	id := <-started
+	cancel()
+	<-done
+	if id != queued.ID {
+		t.Errorf("expected job %s to start, got %s", queued.ID, id)
+	}
+}
@@ -0,0 +1,280 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Event statuses.
+const (
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+// SignatureHeader is the header with the HMAC-SHA256 of the payload, in the
+// form "sha256=<hex>".
+const SignatureHeader = "X-Vidai-Signature"
+
+// Event is the payload sent when a run finishes.
+type Event struct {
+	Command string `json:"command"`
+	Status  string `json:"status"`
+	// TaskID is the last runway task of the run.
+	TaskID  string    `json:"taskId,omitempty"`
+	TaskIDs []string  `json:"taskIds,omitempty"`
+	Reason  string    `json:"reason,omitempty"`
+	URLs    []string  `json:"urls,omitempty"`
+	Output  string    `json:"output,omitempty"`
+	Time    time.Time `json:"time"`
+}
+
+// AddTask records a runway task generated during the run.
+func (e *Event) AddTask(id, u string) {
+	e.TaskID = id
+	if id != "" {
+		e.TaskIDs = append(e.TaskIDs, id)
+	}
+	if u != "" {
+		e.URLs = append(e.URLs, u)
+	}
+}
+
+// Merge records the tasks of another event, such as the event of a nested
+// run.
+func (e *Event) Merge(other *Event) {
+	if other.TaskID != "" {
+		e.TaskID = other.TaskID
+	}
+	e.TaskIDs = append(e.TaskIDs, other.TaskIDs...)
+	e.URLs = append(e.URLs, other.URLs...)
+}
+
+type Config struct {
+	// URL where the event is posted as JSON (optional).
+	URL string
+	// Secret used to sign the payload (optional).
+	Secret string
+	// Command launched when the run succeeds (optional). The placeholders
+	// {output}, {task_id} and {url} are replaced with the event values.
+	Command string
+	// Retries is the number of times a failed webhook is retried,
+	// defaults to 3.
+	Retries int
+	// Backoff is the wait before the first retry, it doubles after each
+	// retry. Defaults to 2 seconds.
+	Backoff time.Duration
+	Debug   bool
+}
+
+// Notifier sends webhooks and launches commands when runs finish.
+type Notifier struct {
+	url     string
+	secret  string
+	command []string
+	retries int
+	backoff time.Duration
+	debug   bool
+	client  *http.Client
+}
+
+// New creates a notifier, it does nothing if neither the URL nor the command
+// are set.
+func New(cfg *Config) (*Notifier, error) {
+	n := &Notifier{
+		url:     cfg.URL,
+		secret:  cfg.Secret,
+		retries: cfg.Retries,
+		backoff: cfg.Backoff,
+		debug:   cfg.Debug,
+		client:  &http.Client{Timeout: 30 * time.Second},
+	}
+	if n.retries == 0 {
+		n.retries = 3
+	}
+	if n.backoff == 0 {
+		n.backoff = 2 * time.Second
+	}
+	if cfg.URL != "" {
+		u, err := url.Parse(cfg.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return nil, fmt.Errorf("notify: invalid url %q", cfg.URL)
+		}
+	}
+	if cfg.Command != "" {
+		args, err := splitArgs(cfg.Command)
+		if err != nil {
+			return nil, fmt.Errorf("notify: invalid command: %w", err)
+		}
+		n.command = args
+	}
+	return n, nil
+}
+
+// Done completes the event with the result of the run and notifies it.
+// Notification errors are logged instead of returned so that they don't
+// hide the result of the run.
+func (n *Notifier) Done(ctx context.Context, ev *Event, err error) {
+	if n.url == "" && len(n.command) == 0 {
+		return
+	}
+	ev.Time = time.Now().UTC()
+	ev.Status = StatusSucceeded
+	if err != nil {
+		ev.Status = StatusFailed
+		ev.Reason = err.Error()
+	}
+
+	// The webhook is sent even if the run was interrupted
+	if n.url != "" {
+		sendCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Minute)
+		defer cancel()
+		if err := n.Send(sendCtx, ev); err != nil {
+			log.Println(err)
+		}
+	}
+	if len(n.command) > 0 && ev.Status == StatusSucceeded {
+		if err := n.Run(ctx, ev); err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+// Send posts the event to the webhook URL, retrying on network errors and
+// server errors.
+func (n *Notifier) Send(ctx context.Context, ev *Event) error {
+	body, err := json.Marshal(ev)
+	if err != nil {
+		return fmt.Errorf("notify: couldn't marshal event: %w", err)
+	}
+	wait := n.backoff
+	for attempt := 0; ; attempt++ {
+		retry, err := n.post(ctx, body)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt >= n.retries {
+			return fmt.Errorf("notify: couldn't send webhook: %w", err)
+		}
+		log.Printf("notify: webhook failed, retrying in %s: %v\n", wait, err)
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("notify: couldn't send webhook: %w", ctx.Err())
+		case <-time.After(wait):
+		}
+		wait *= 2
+	}
+}
+
+// post sends the body once and returns whether the request can be retried.
+func (n *Notifier) post(ctx context.Context, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "vidai")
+	if n.secret != "" {
+		req.Header.Set(SignatureHeader, Sign(n.secret, body))
+	}
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	b, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if n.debug {
+		log.Printf("notify: webhook response %d %s\n", resp.StatusCode, string(b))
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+	return retry, fmt.Errorf("status %d: %s", resp.StatusCode, strings.TrimSpace(string(b)))
+}
+
+// Sign returns the signature of the body, receivers can compute it with the
+// shared secret to verify the payload.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	_, _ = mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Run launches the command replacing its placeholders with the event values.
+// The command isn't run by a shell, so values don't need to be escaped.
+func (n *Notifier) Run(ctx context.Context, ev *Event) error {
+	var u string
+	if len(ev.URLs) > 0 {
+		u = ev.URLs[len(ev.URLs)-1]
+	}
+	r := strings.NewReplacer("{output}", ev.Output, "{task_id}", ev.TaskID, "{url}", u)
+	args := make([]string, 0, len(n.command))
+	for _, a := range n.command {
+		args = append(args, r.Replace(a))
+	}
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("notify: command %q failed: %w", args[0], err)
+	}
+	return nil
+}
+
+// splitArgs splits a command line into arguments, supporting single and
+// double quotes and backslash escapes.
+func splitArgs(s string) ([]string, error) {
+	var args []string
+	var cur strings.Builder
+	var quote rune
+	inArg, escaped := false, false
+	for _, c := range s {
+		switch {
+		case escaped:
+			cur.WriteRune(c)
+			escaped = false
+		case c == '\\' && quote != '\'':
+			escaped, inArg = true, true
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			} else {
+				cur.WriteRune(c)
+			}
+		case c == '\'' || c == '"':
+			quote, inArg = c, true
+		case c == ' ' || c == '\t' || c == '\n':
+			if inArg {
+				args = append(args, cur.String())
+				cur.Reset()
+				inArg = false
+			}
+		default:
+			cur.WriteRune(c)
+			inArg = true
+		}
+	}
+	if quote != 0 || escaped {
+		return nil, fmt.Errorf("unterminated quote or escape in %q", s)
+	}
+	if inArg {
+		args = append(args, cur.String())
+	}
+	if len(args) == 0 {
+		return nil, fmt.Errorf("empty command")
+	}
+	return args, nil
+}
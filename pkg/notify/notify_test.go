@@ -0,0 +1,135 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSend(t *testing.T) {
+	var calls atomic.Int32
+	var got Event
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		if r.Header.Get(SignatureHeader) != Sign("secret", b) {
+			t.Errorf("invalid signature %s", r.Header.Get(SignatureHeader))
+		}
+		// Fail the first attempt to check the retry
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		if err := json.Unmarshal(b, &got); err != nil {
+			t.Error(err)
+		}
+	}))
+	defer srv.Close()
+
+	n, err := New(&Config{URL: srv.URL, Secret: "secret", Backoff: time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ev := &Event{Command: "extend", Output: "out.mp4"}
+	ev.AddTask("task-1", "https://a.url.test/1.mp4")
+	ev.AddTask("task-2", "https://a.url.test/2.mp4")
+	n.Done(context.Background(), ev, errors.New("boom"))
+
+	if calls.Load() != 2 {
+		t.Errorf("expected 2 calls, got %d", calls.Load())
+	}
+	if got.Status != StatusFailed || got.Reason != "boom" || got.TaskID != "task-2" ||
+		!reflect.DeepEqual(got.TaskIDs, []string{"task-1", "task-2"}) || len(got.URLs) != 2 {
+		t.Errorf("unexpected event %+v", got)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	ev := &Event{Command: "sequence"}
+	ev.AddTask("task-1", "https://a.url.test/1.mp4")
+	nested := &Event{Command: "extend"}
+	nested.AddTask("task-2", "https://a.url.test/2.mp4")
+	nested.AddTask("task-3", "")
+	ev.Merge(nested)
+	ev.Merge(&Event{Command: "generate"})
+
+	if ev.TaskID != "task-3" ||
+		!reflect.DeepEqual(ev.TaskIDs, []string{"task-1", "task-2", "task-3"}) ||
+		!reflect.DeepEqual(ev.URLs, []string{"https://a.url.test/1.mp4", "https://a.url.test/2.mp4"}) {
+		t.Errorf("unexpected event %+v", ev)
+	}
+}
+
+func TestSendNoRetry(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	n, err := New(&Config{URL: srv.URL, Backoff: time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := n.Send(context.Background(), &Event{}); err == nil {
+		t.Error("expected error")
+	}
+	if calls.Load() != 1 {
+		t.Errorf("expected 1 call, got %d", calls.Load())
+	}
+}
+
+func TestRun(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires sh")
+	}
+	dir := t.TempDir()
+	out := filepath.Join(dir, "done.txt")
+	n, err := New(&Config{Command: `sh -c 'echo "$1" > "$2"' sh {output} ` + out})
+	if err != nil {
+		t.Fatal(err)
+	}
+	n.Done(context.Background(), &Event{Output: "my video.mp4"}, nil)
+	b, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "my video.mp4\n" {
+		t.Errorf("unexpected output %q", b)
+	}
+
+	// The command isn't run if the run failed
+	if err := os.Remove(out); err != nil {
+		t.Fatal(err)
+	}
+	n.Done(context.Background(), &Event{Output: "a.mp4"}, errors.New("boom"))
+	if _, err := os.Stat(out); !os.IsNotExist(err) {
+		t.Error("expected command not to run")
+	}
+}
+
+func TestSplitArgs(t *testing.T) {
+	got, err := splitArgs(`cp {output} "/tmp/my dir/" 'a b' c\ d`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"cp", "{output}", "/tmp/my dir/", "a b", "c d"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	for _, in := range []string{"", "  ", `cp "a`} {
+		if _, err := splitArgs(in); err == nil {
+			t.Errorf("%q: expected error", in)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package preprocess
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+
+	"golang.org/x/image/bmp"
+	"golang.org/x/image/draw"
+	"golang.org/x/image/tiff"
+	"golang.org/x/image/webp"
+)
+
+// Fit modes to adapt images to the target aspect ratio.
+const (
+	FitCrop    = "crop"
+	FitPad     = "pad"
+	FitStretch = "stretch"
+	FitNone    = "none"
+)
+
+type Config struct {
+	// Width and Height of the target video, defaults to 1280x768.
+	Width  int
+	Height int
+	// Portrait swaps the target width and height.
+	Portrait bool
+	// Fit is the mode used to adapt the image to the target aspect ratio.
+	Fit string
+	// Quality of the JPEG output, defaults to 95.
+	Quality int
+}
+
+// Process converts the image to JPEG or PNG, applies its EXIF orientation,
+// adapts it to the target aspect ratio and downsizes it if it is bigger than
+// the target size.
+// The resulting image and its extension are returned. EXIF metadata is
+// stripped because the image is always encoded again.
+func Process(data []byte, cfg *Config) ([]byte, string, error) {
+	width, height := cfg.Width, cfg.Height
+	if width == 0 || height == 0 {
+		width, height = 1280, 768
+	}
+	if cfg.Portrait {
+		width, height = height, width
+	}
+	quality := cfg.Quality
+	if quality == 0 {
+		quality = 95
+	}
+
+	format, err := Sniff(data)
+	if err != nil {
+		return nil, "", err
+	}
+	img, err := decode(format, data)
+	if err != nil {
+		return nil, "", fmt.Errorf("preprocess: couldn't decode %s image: %w", format, err)
+	}
+	if format == "jpeg" {
+		img = orient(img, exifOrientation(data))
+	}
+
+	switch cfg.Fit {
+	case FitCrop, "":
+		img = crop(img, width, height)
+		img = fitInside(img, width, height)
+	case FitPad:
+		img = fitInside(img, width, height)
+		img = pad(img, width, height)
+	case FitStretch:
+		img = resize(img, width, height)
+	case FitNone:
+		img = fitInside(img, width, height)
+	default:
+		return nil, "", fmt.Errorf("preprocess: unknown fit mode %q", cfg.Fit)
+	}
+
+	// PNG images are kept as PNG to preserve transparency, other formats are
+	// converted to JPEG.
+	var buf bytes.Buffer
+	if format == "png" {
+		if err := png.Encode(&buf, img); err != nil {
+			return nil, "", fmt.Errorf("preprocess: couldn't encode png: %w", err)
+		}
+		return buf.Bytes(), "png", nil
+	}
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+		return nil, "", fmt.Errorf("preprocess: couldn't encode jpeg: %w", err)
+	}
+	return buf.Bytes(), "jpg", nil
+}
+
+// Sniff returns the real format of an image regardless of its extension.
+func Sniff(data []byte) (string, error) {
+	switch http.DetectContentType(data) {
+	case "image/jpeg":
+		return "jpeg", nil
+	case "image/png":
+		return "png", nil
+	case "image/gif":
+		return "gif", nil
+	case "image/webp":
+		return "webp", nil
+	case "image/bmp":
+		return "bmp", nil
+	}
+	if len(data) >= 4 && (bytes.Equal(data[:4], []byte("II*\x00")) || bytes.Equal(data[:4], []byte("MM\x00*"))) {
+		return "tiff", nil
+	}
+	// HEIC/HEIF images start with an ftyp box
+	if len(data) >= 12 && string(data[4:8]) == "ftyp" {
+		switch string(data[8:12]) {
+		case "heic", "heix", "hevc", "hevx", "heim", "heis", "mif1", "msf1", "avif":
+			return "", fmt.Errorf("preprocess: %s images aren't supported, convert them to jpeg or png", string(data[8:12]))
+		}
+	}
+	return "", fmt.Errorf("preprocess: unknown image format")
+}
+
+func decode(format string, data []byte) (image.Image, error) {
+	r := bytes.NewReader(data)
+	switch format {
+	case "jpeg":
+		return jpeg.Decode(r)
+	case "png":
+		return png.Decode(r)
+	case "gif":
+		return gif.Decode(r)
+	case "webp":
+		return webp.Decode(r)
+	case "bmp":
+		return bmp.Decode(r)
+	case "tiff":
+		return tiff.Decode(r)
+	default:
+		return nil, fmt.Errorf("unsupported format %s", format)
+	}
+}
+
+// crop crops the center of the image to match the aspect ratio of the given
+// width and height.
+func crop(img image.Image, width, height int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	cw, ch := w, h
+	if w*height > h*width {
+		cw = h * width / height
+	} else {
+		ch = w * height / width
+	}
+	if cw == w && ch == h {
+		return img
+	}
+	x := b.Min.X + (w-cw)/2
+	y := b.Min.Y + (h-ch)/2
+	dst := image.NewRGBA(image.Rect(0, 0, cw, ch))
+	draw.Draw(dst, dst.Bounds(), img, image.Pt(x, y), draw.Src)
+	return dst
+}
+
+// pad centers the image in a black canvas with the aspect ratio of the given
+// width and height.
+func pad(img image.Image, width, height int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	pw, ph := w, h
+	if w*height > h*width {
+		ph = w * height / width
+	} else {
+		pw = h * width / height
+	}
+	if pw == w && ph == h {
+		return img
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, pw, ph))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(color.Black), image.Point{}, draw.Src)
+	r := image.Rect((pw-w)/2, (ph-h)/2, (pw-w)/2+w, (ph-h)/2+h)
+	draw.Draw(dst, r, img, b.Min, draw.Over)
+	return dst
+}
+
+// fitInside downsizes the image to fit inside the given width and height
+// keeping its aspect ratio. Smaller images aren't upscaled.
+func fitInside(img image.Image, width, height int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= width && h <= height {
+		return img
+	}
+	if w*height > h*width {
+		return resize(img, width, h*width/w)
+	}
+	return resize(img, w*height/h, height)
+}
+
+func resize(img image.Image, width, height int) image.Image {
+	b := img.Bounds()
+	if b.Dx() == width && b.Dy() == height {
+		return img
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, b, draw.Src, nil)
+	return dst
+}
@@ -0,0 +1,113 @@
+package preprocess
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func testImage(t *testing.T, w, h int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{uint8(x), uint8(y), 0, 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestProcess(t *testing.T) {
+	tests := []struct {
+		name          string
+		width, height int
+		cfg           Config
+		wantW, wantH  int
+	}{
+		{"crop landscape", 1400, 1400, Config{Fit: FitCrop}, 1280, 768},
+		{"crop portrait", 1400, 1400, Config{Fit: FitCrop, Portrait: true}, 768, 1280},
+		{"crop small", 500, 500, Config{Fit: FitCrop}, 500, 300},
+		{"pad", 1000, 1000, Config{Fit: FitPad}, 1280, 768},
+		{"stretch", 100, 100, Config{Fit: FitStretch}, 1280, 768},
+		{"none", 1600, 1600, Config{Fit: FitNone}, 768, 768},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, ext, err := Process(testImage(t, tt.width, tt.height), &tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ext != "jpg" {
+				t.Errorf("expected jpg, got %s", ext)
+			}
+			cfg, err := jpeg.DecodeConfig(bytes.NewReader(out))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.Width != tt.wantW || cfg.Height != tt.wantH {
+				t.Errorf("expected %dx%d, got %dx%d", tt.wantW, tt.wantH, cfg.Width, cfg.Height)
+			}
+		})
+	}
+}
+
+func TestProcessPNG(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 10, 6))); err != nil {
+		t.Fatal(err)
+	}
+	_, ext, err := Process(buf.Bytes(), &Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ext != "png" {
+		t.Errorf("expected png, got %s", ext)
+	}
+}
+
+func TestSniffHEIC(t *testing.T) {
+	data := append([]byte{0, 0, 0, 24}, []byte("ftypheic0000")...)
+	if _, err := Sniff(data); err == nil {
+		t.Error("expected error for heic image")
+	}
+}
+
+func TestOrient(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 3, 2))
+	img.Set(0, 0, color.RGBA{255, 0, 0, 255})
+	// Orientation 6 rotates 90 degrees clockwise, so the top left pixel
+	// becomes the top right pixel.
+	out := orient(img, 6)
+	if b := out.Bounds(); b.Dx() != 2 || b.Dy() != 3 {
+		t.Fatalf("unexpected bounds %v", b)
+	}
+	if r, _, _, _ := out.At(1, 0).RGBA(); r != 0xffff {
+		t.Errorf("expected red pixel at top right")
+	}
+}
+
+func TestSharpness(t *testing.T) {
+	sharp := image.NewGray(image.Rect(0, 0, 32, 32))
+	blurred := image.NewGray(image.Rect(0, 0, 32, 32))
+	for y := 0; y < 32; y++ {
+		for x := 0; x < 32; x++ {
+			if (x/4+y/4)%2 == 0 {
+				sharp.SetGray(x, y, color.Gray{Y: 255})
+			}
+			blurred.SetGray(x, y, color.Gray{Y: uint8(x * 8)})
+		}
+	}
+	if s, b := Sharpness(sharp), Sharpness(blurred); s <= b {
+		t.Errorf("expected sharp image to score higher: %f <= %f", s, b)
+	}
+	if s := Sharpness(image.NewGray(image.Rect(0, 0, 16, 16))); s != 0 {
+		t.Errorf("expected flat image to score 0, got %f", s)
+	}
+}
@@ -0,0 +1,35 @@
+package preprocess
+
+import (
+	"image"
+)
+
+// Sharpness returns the variance of the Laplacian of the image luminance.
+// Higher values mean more edges and detail, while blurred frames have low
+// values.
+func Sharpness(img image.Image) float64 {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w < 3 || h < 3 {
+		return 0
+	}
+	lum := make([]float64, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			r, g, bl, _ := img.At(b.Min.X+x, b.Min.Y+y).RGBA()
+			lum[y*w+x] = (0.299*float64(r) + 0.587*float64(g) + 0.114*float64(bl)) / 257
+		}
+	}
+	var sum, sq float64
+	for y := 1; y < h-1; y++ {
+		for x := 1; x < w-1; x++ {
+			i := y*w + x
+			v := lum[i-w] + lum[i+w] + lum[i-1] + lum[i+1] - 4*lum[i]
+			sum += v
+			sq += v * v
+		}
+	}
+	n := float64((w - 2) * (h - 2))
+	mean := sum / n
+	return sq/n - mean*mean
+}
@@ -0,0 +1,124 @@
+package prompts
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// models are the values accepted by the model option.
+var models = map[string]bool{
+	"gen2":       true,
+	"gen3":       true,
+	"gen3-turbo": true,
+}
+
+// Step is the configuration of a single generation step.
+type Step struct {
+	Prompt string
+	// Model overrides the default model if it isn't empty.
+	Model string
+	// Seconds overrides the default duration if it isn't zero.
+	Seconds int
+}
+
+// Load returns the steps from an inline list separated by semicolons or from
+// a file with one step per line.
+// Each step is a text prompt optionally followed by options after a pipe,
+// e.g. "a car driving at night | model=gen3-turbo seconds=10".
+// Empty lines and lines starting with # are ignored in files.
+func Load(inline, file string) ([]Step, error) {
+	switch {
+	case inline != "" && file != "":
+		return nil, fmt.Errorf("prompts: inline prompts and prompt file can't be used together")
+	case inline != "":
+		return Parse(strings.Split(inline, ";"))
+	case file != "":
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("prompts: couldn't read prompt file: %w", err)
+		}
+		var lines []string
+		scanner := bufio.NewScanner(bytes.NewReader(b))
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			lines = append(lines, line)
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("prompts: couldn't read prompt file: %w", err)
+		}
+		return Parse(lines)
+	default:
+		return nil, nil
+	}
+}
+
+// Parse parses each item as a step.
+func Parse(items []string) ([]Step, error) {
+	var steps []Step
+	for i, item := range items {
+		step, err := parseStep(item)
+		if err != nil {
+			return nil, fmt.Errorf("prompts: step %d: %w", i+1, err)
+		}
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
+
+func parseStep(item string) (Step, error) {
+	text, opts, _ := strings.Cut(item, "|")
+	step := Step{Prompt: strings.TrimSpace(text)}
+	for _, opt := range strings.Fields(opts) {
+		k, v, ok := strings.Cut(opt, "=")
+		if !ok {
+			return Step{}, fmt.Errorf("invalid option %q", opt)
+		}
+		switch k {
+		case "model":
+			if !models[v] {
+				return Step{}, fmt.Errorf("invalid model %q (gen2, gen3 or gen3-turbo)", v)
+			}
+			step.Model = v
+		case "seconds":
+			n, err := strconv.Atoi(strings.TrimSuffix(v, "s"))
+			if err != nil || n <= 0 {
+				return Step{}, fmt.Errorf("invalid seconds %q", v)
+			}
+			step.Seconds = n
+		default:
+			return Step{}, fmt.Errorf("unknown option %q", k)
+		}
+	}
+	return step, nil
+}
+
+// At returns the step for the given index. Indexes beyond the last step
+// reuse the last one, and an empty step is returned if there are no steps.
+func At(steps []Step, i int) Step {
+	if len(steps) == 0 {
+		return Step{}
+	}
+	if i >= len(steps) {
+		return steps[len(steps)-1]
+	}
+	return steps[i]
+}
+
+// WithDefaults returns the step using the given model and seconds if they
+// aren't overridden.
+func (s Step) WithDefaults(model string, seconds int) Step {
+	if s.Model == "" {
+		s.Model = model
+	}
+	if s.Seconds == 0 {
+		s.Seconds = seconds
+	}
+	return s
+}
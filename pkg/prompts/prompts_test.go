@@ -0,0 +1,76 @@
+package prompts
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	want := []Step{
+		{Prompt: "a car driving at night"},
+		{Prompt: "the car stops", Model: "gen3-turbo", Seconds: 10},
+		{Prompt: "", Seconds: 5},
+	}
+
+	got, err := Load("a car driving at night; the car stops | model=gen3-turbo seconds=10;| seconds=5s", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("inline: got %+v, want %+v", got, want)
+	}
+
+	file := filepath.Join(t.TempDir(), "prompts.txt")
+	content := "# shots\na car driving at night\n\nthe car stops | model=gen3-turbo seconds=10\n| seconds=5\n"
+	if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	got, err = Load("", file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("file: got %+v, want %+v", got, want)
+	}
+
+	if _, err := Load("a | fps=24", ""); err == nil {
+		t.Error("expected error for unknown option")
+	}
+	for _, model := range []string{"gen3turbo", "gen4", "Gen3", ""} {
+		if _, err := Load("a | model="+model, ""); err == nil {
+			t.Errorf("expected error for model %q", model)
+		}
+	}
+	for _, model := range []string{"gen2", "gen3", "gen3-turbo"} {
+		steps, err := Load("a | model="+model, "")
+		if err != nil {
+			t.Errorf("model %q: %v", model, err)
+			continue
+		}
+		if steps[0].Model != model {
+			t.Errorf("got model %q, want %q", steps[0].Model, model)
+		}
+	}
+	if _, err := Load("a", file); err == nil {
+		t.Error("expected error for inline prompts and file")
+	}
+}
+
+func TestAt(t *testing.T) {
+	steps := []Step{{Prompt: "a"}, {Prompt: "b"}}
+	if s := At(steps, 1); s.Prompt != "b" {
+		t.Errorf("got %q, want b", s.Prompt)
+	}
+	if s := At(steps, 5); s.Prompt != "b" {
+		t.Errorf("got %q, want b", s.Prompt)
+	}
+	if s := At(nil, 0); s.Prompt != "" {
+		t.Errorf("got %q, want empty", s.Prompt)
+	}
+	s := Step{Prompt: "a", Seconds: 10}.WithDefaults("gen3-turbo", 5)
+	if s.Model != "gen3-turbo" || s.Seconds != 10 {
+		t.Errorf("unexpected step %+v", s)
+	}
+}
@@ -19,6 +19,25 @@ import (
 	"github.com/igolaizola/vidai/pkg/ratelimit"
 )
 
+// ErrTokenExpired is returned when the token can't be used anymore.
+var ErrTokenExpired = errors.New("runway: token expired")
+
+// IsAuthError returns true if the error is caused by an expired or rejected
+// token, so that retrying with the same token won't succeed.
+func IsAuthError(err error) bool {
+	if errors.Is(err, ErrTokenExpired) {
+		return true
+	}
+	var errStatus errStatusCode
+	if errors.As(err, &errStatus) {
+		switch int(errStatus) {
+		case http.StatusUnauthorized, http.StatusForbidden:
+			return true
+		}
+	}
+	return false
+}
+
 type Client struct {
 	client     fhttp.Client
 	debug      bool
@@ -27,6 +46,7 @@ type Client struct {
 	expiration time.Time
 	teamID     int
 	folder     string
+	cache      UploadCache
 }
 
 type Config struct {
@@ -35,6 +55,15 @@ type Config struct {
 	Debug  bool
 	Proxy  string
 	Folder string
+	// UploadCache is used to reuse previous uploads of the same data (optional)
+	UploadCache UploadCache
+}
+
+// UploadCache stores uploaded datasets using the MD5 of their data as key.
+type UploadCache interface {
+	Get(key string) (string, string, bool)
+	Set(key, url, datasetID string) error
+	Delete(key string) error
 }
 
 func New(cfg *Config) (*Client, error) {
@@ -62,7 +91,7 @@ func New(cfg *Config) (*Client, error) {
 	}
 	expiration := time.Unix(int64(exp), 0)
 	if expiration.Before(time.Now()) {
-		return nil, fmt.Errorf("runway: token expired")
+		return nil, ErrTokenExpired
 	}
 	client := fhttp.NewClient(2*time.Minute, true, cfg.Proxy)
 	return &Client{
@@ -72,6 +101,7 @@ func New(cfg *Config) (*Client, error) {
 		token:      cfg.Token,
 		expiration: expiration,
 		folder:     folder,
+		cache:      cfg.UploadCache,
 	}, nil
 }
 
@@ -90,7 +120,7 @@ var backoff = []time.Duration{
 
 func (c *Client) do(ctx context.Context, method, path string, in, out any) ([]byte, error) {
 	if time.Now().After(c.expiration) {
-		return nil, fmt.Errorf("runway: token expired")
+		return nil, ErrTokenExpired
 	}
 	maxAttempts := 3
 	attempts := 0
@@ -160,11 +190,7 @@ func (c *Client) doAttempt(ctx context.Context, method, path string, in, out any
 	var logBody string
 	if f, ok := in.(*uploadFile); ok {
 		body = f.data
-		ext := f.extension
-		if ext == "jpg" {
-			ext = "jpeg"
-		}
-		contentType = fmt.Sprintf("image/%s", ext)
+		contentType = f.contentType
 		reqBody = bytes.NewReader(body)
 		logBody = fmt.Sprintf("%d bytes", len(body))
 	} else if in != nil {
@@ -191,8 +217,12 @@ func (c *Client) doAttempt(ctx context.Context, method, path string, in, out any
 	}
 	c.addHeaders(req, path, contentType, uploadLen)
 
-	unlock := c.ratelimit.Lock(ctx)
-	defer unlock()
+	// Uploads go directly to the storage service, so they aren't rate limited
+	// and their parts can be sent concurrently.
+	if uploadLen == 0 {
+		unlock := c.ratelimit.Lock(ctx)
+		defer unlock()
+	}
 
 	resp, err := c.client.Do(req)
 	if err != nil {
@@ -205,7 +235,7 @@ func (c *Client) doAttempt(ctx context.Context, method, path string, in, out any
 	}
 
 	logResp := string(respBody)
-	if method == "GET" && (strings.Contains(path, "amazonaws.com") || strings.Contains(path, "cloudfront.net")) {
+	if method == "GET" && strings.HasPrefix(path, "http") {
 		logResp = fmt.Sprintf("%d bytes", len(respBody))
 	}
 	c.log("runway: response %s %s %d %s", method, path, resp.StatusCode, logResp)
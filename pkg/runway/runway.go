@@ -6,12 +6,15 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"log"
 	"math/rand"
 	"net/http"
 	"os"
 	"path/filepath"
 	"regexp"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -68,10 +71,12 @@ type uploadResponse struct {
 }
 
 type uploadCompleteRequest struct {
-	Parts []struct {
-		PartNumber int    `json:"PartNumber"`
-		ETag       string `json:"ETag"`
-	} `json:"parts"`
+	Parts []uploadPart `json:"parts"`
+}
+
+type uploadPart struct {
+	PartNumber int    `json:"PartNumber"`
+	ETag       string `json:"ETag"`
 }
 
 type uploadCompleteResponse struct {
@@ -79,8 +84,8 @@ type uploadCompleteResponse struct {
 }
 
 type uploadFile struct {
-	data      []byte
-	extension string
+	data        []byte
+	contentType string
 }
 
 type datasetRequest struct {
@@ -105,73 +110,97 @@ type datasetResponse struct {
 	} `json:"dataset"`
 }
 
-func (c *Client) Upload(ctx context.Context, name string, data []byte) (string, string, error) {
-	ext := strings.TrimPrefix(".", filepath.Ext(name))
-	file := &uploadFile{
-		data:      data,
-		extension: ext,
-	}
+const (
+	// uploadPartSize is the size of each part of a multipart upload.
+	uploadPartSize = 10 * 1024 * 1024
+	// uploadConcurrency is the maximum number of parts uploaded at once.
+	uploadConcurrency = 4
+)
 
-	// Calculate etag
-	etag := fmt.Sprintf("%x", md5.Sum(data))
+// Upload uploads the data read from r as a new dataset and returns its URL
+// and dataset ID.
+// Data is split in parts of uploadPartSize that are uploaded concurrently.
+func (c *Client) Upload(ctx context.Context, name string, r io.Reader) (string, string, error) {
+	return c.upload(ctx, name, r, "", nil)
+}
 
-	types := []string{
-		"DATASET",
-		"DATASET_PREVIEW",
+// UploadVideo uploads a video as a new dataset using the preview image as its
+// thumbnail and returns the video URL and dataset ID.
+func (c *Client) UploadVideo(ctx context.Context, name string, r io.Reader, previewName string, preview io.Reader) (string, string, error) {
+	if typ, _ := mediaType(name); typ != "video" {
+		return "", "", fmt.Errorf("runway: %s is not a video", name)
 	}
-	var imageURL string
-	var uploadID, previewUploadID string
-	for _, t := range types {
-		// Get upload URL
-		uploadReq := &uploadRequest{
-			Filename:      name,
-			NumberOfParts: 1,
-			Type:          t,
-		}
-		var uploadResp uploadResponse
-		if _, err := c.do(ctx, "POST", "uploads", uploadReq, &uploadResp); err != nil {
-			return "", "", fmt.Errorf("runway: couldn't obtain upload url: %w", err)
-		}
-		if len(uploadResp.UploadURLs) == 0 {
-			return "", "", fmt.Errorf("runway: no upload urls returned")
-		}
+	return c.upload(ctx, name, r, previewName, preview)
+}
 
-		// Upload file
-		uploadURL := uploadResp.UploadURLs[0]
-		if _, err := c.do(ctx, "PUT", uploadURL, file, nil); err != nil {
-			return "", "", fmt.Errorf("runway: couldn't upload file: %w", err)
-		}
+func (c *Client) upload(ctx context.Context, name string, r io.Reader, previewName string, preview io.Reader) (string, string, error) {
+	typ, contentType := mediaType(name)
+	if typ == "video" && preview == nil {
+		return "", "", fmt.Errorf("runway: video preview is required")
+	}
 
-		// Complete upload
-		completeURL := fmt.Sprintf("uploads/%s/complete", uploadResp.ID)
-		completeReq := &uploadCompleteRequest{
-			Parts: []struct {
-				PartNumber int    `json:"PartNumber"`
-				ETag       string `json:"ETag"`
-			}{
-				{
-					PartNumber: 1,
-					ETag:       etag,
-				},
-			},
+	if err := c.loadTeamID(ctx); err != nil {
+		return "", "", fmt.Errorf("runway: couldn't load team id: %w", err)
+	}
+
+	src, size, cleanup, err := toReaderAt(r)
+	if err != nil {
+		return "", "", err
+	}
+	defer cleanup()
+
+	// Check if the same data has already been uploaded
+	var key string
+	if c.cache != nil {
+		h := md5.New()
+		if _, err := io.Copy(h, io.NewSectionReader(src, 0, size)); err != nil {
+			return "", "", fmt.Errorf("runway: couldn't calculate md5: %w", err)
 		}
-		var completeResp uploadCompleteResponse
-		if _, err := c.do(ctx, "POST", completeURL, completeReq, &completeResp); err != nil {
-			return "", "", fmt.Errorf("runway: couldn't complete upload: %w", err)
+		key = fmt.Sprintf("%x", h.Sum(nil))
+		if u, id, ok := c.cache.Get(key); ok {
+			exists, err := c.assetExists(ctx, id)
+			if err != nil {
+				return "", "", fmt.Errorf("runway: couldn't check cached upload: %w", err)
+			}
+			if exists {
+				c.log("runway: upload cache hit %s %s", key, id)
+				return u, id, nil
+			}
+			// The dataset was deleted remotely, so it is uploaded again
+			c.log("runway: cached upload %s %s was deleted", key, id)
+			if err := c.cache.Delete(key); err != nil {
+				log.Println(fmt.Errorf("runway: couldn't remove upload from cache: %w", err))
+			}
 		}
+	}
 
-		c.log("runway: upload complete %s", completeResp.URL)
-		if completeResp.URL == "" {
-			return "", "", fmt.Errorf("runway: empty image url for type %s", t)
+	// Images are used as their own preview
+	previewSrc, previewSize, previewContentType := src, size, contentType
+	if preview != nil {
+		var previewCleanup func()
+		previewSrc, previewSize, previewCleanup, err = toReaderAt(preview)
+		if err != nil {
+			return "", "", err
 		}
-		imageURL = completeResp.URL
+		defer previewCleanup()
+		_, previewContentType = mediaType(previewName)
+	} else {
+		previewName = name
+	}
 
-		switch t {
-		case "DATASET":
-			uploadID = uploadResp.ID
-		case "DATASET_PREVIEW":
-			previewUploadID = uploadResp.ID
-		}
+	uploadID, datasetURL, err := c.uploadParts(ctx, name, "DATASET", contentType, src, size)
+	if err != nil {
+		return "", "", err
+	}
+	previewUploadID, previewURL, err := c.uploadParts(ctx, previewName, "DATASET_PREVIEW", previewContentType, previewSrc, previewSize)
+	if err != nil {
+		return "", "", err
+	}
+
+	// The preview URL is returned for images and the dataset URL for videos
+	assetURL := previewURL
+	if typ == "video" {
+		assetURL = datasetURL
 	}
 
 	// Dataset request
@@ -185,8 +214,8 @@ func (c *Client) Upload(ctx context.Context, name string, data []byte) (string,
 			Type        string `json:"type"`
 			IsDirectory bool   `json:"isDirectory"`
 		}{
-			Name:        "image",
-			Type:        "image",
+			Name:        typ,
+			Type:        typ,
 			IsDirectory: false,
 		},
 		AsTeamID: c.teamID,
@@ -199,7 +228,159 @@ func (c *Client) Upload(ctx context.Context, name string, data []byte) (string,
 		return "", "", fmt.Errorf("runway: empty dataset url or id")
 	}
 
-	return imageURL, datasetResp.Dataset.ID, nil
+	if c.cache != nil {
+		if err := c.cache.Set(key, assetURL, datasetResp.Dataset.ID); err != nil {
+			log.Println(fmt.Errorf("runway: couldn't save upload to cache: %w", err))
+		}
+	}
+	return assetURL, datasetResp.Dataset.ID, nil
+}
+
+// mediaType returns the dataset type and the content type of a file based on
+// its extension.
+func mediaType(name string) (string, string) {
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(name), "."))
+	switch ext {
+	case "mp4", "m4v":
+		return "video", "video/mp4"
+	case "mov":
+		return "video", "video/quicktime"
+	case "webm":
+		return "video", "video/webm"
+	case "jpg", "jpeg":
+		return "image", "image/jpeg"
+	default:
+		return "image", fmt.Sprintf("image/%s", ext)
+	}
+}
+
+// uploadParts uploads the data using a multipart upload of the given type and
+// returns the upload ID and URL.
+func (c *Client) uploadParts(ctx context.Context, name, typ, contentType string, src io.ReaderAt, size int64) (string, string, error) {
+	numParts := int((size + uploadPartSize - 1) / uploadPartSize)
+	if numParts == 0 {
+		numParts = 1
+	}
+
+	// Get upload URLs
+	uploadReq := &uploadRequest{
+		Filename:      name,
+		NumberOfParts: numParts,
+		Type:          typ,
+	}
+	var uploadResp uploadResponse
+	if _, err := c.do(ctx, "POST", "uploads", uploadReq, &uploadResp); err != nil {
+		return "", "", fmt.Errorf("runway: couldn't obtain upload url: %w", err)
+	}
+	if len(uploadResp.UploadURLs) != numParts {
+		return "", "", fmt.Errorf("runway: expected %d upload urls, got %d", numParts, len(uploadResp.UploadURLs))
+	}
+
+	// Upload parts with bounded concurrency
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	parts := make([]uploadPart, numParts)
+	errs := make(chan error, numParts)
+	sem := make(chan struct{}, uploadConcurrency)
+	var wg sync.WaitGroup
+	for i, uploadURL := range uploadResp.UploadURLs {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func(i int, uploadURL string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			// Read part
+			offset := int64(i) * uploadPartSize
+			length := size - offset
+			if length > uploadPartSize {
+				length = uploadPartSize
+			}
+			data := make([]byte, length)
+			if _, err := src.ReadAt(data, offset); err != nil && !errors.Is(err, io.EOF) {
+				errs <- fmt.Errorf("runway: couldn't read part %d: %w", i+1, err)
+				cancel()
+				return
+			}
+
+			// Upload part
+			file := &uploadFile{
+				data:        data,
+				contentType: contentType,
+			}
+			if _, err := c.do(ctx, "PUT", uploadURL, file, nil); err != nil {
+				errs <- fmt.Errorf("runway: couldn't upload part %d: %w", i+1, err)
+				cancel()
+				return
+			}
+			parts[i] = uploadPart{
+				PartNumber: i + 1,
+				ETag:       fmt.Sprintf("%x", md5.Sum(data)),
+			}
+		}(i, uploadURL)
+	}
+	wg.Wait()
+	close(errs)
+	if err := <-errs; err != nil {
+		return "", "", err
+	}
+	if err := ctx.Err(); err != nil {
+		return "", "", fmt.Errorf("runway: couldn't upload file: %w", err)
+	}
+
+	// Complete upload
+	completeURL := fmt.Sprintf("uploads/%s/complete", uploadResp.ID)
+	completeReq := &uploadCompleteRequest{
+		Parts: parts,
+	}
+	var completeResp uploadCompleteResponse
+	if _, err := c.do(ctx, "POST", completeURL, completeReq, &completeResp); err != nil {
+		return "", "", fmt.Errorf("runway: couldn't complete upload: %w", err)
+	}
+
+	c.log("runway: upload complete %s", completeResp.URL)
+	if completeResp.URL == "" {
+		return "", "", fmt.Errorf("runway: empty upload url for type %s", typ)
+	}
+	return uploadResp.ID, completeResp.URL, nil
+}
+
+// toReaderAt returns a reader that can be read at any offset along with its
+// size. Readers that don't support random access are copied to a temporary
+// file that is removed by the returned cleanup function.
+func toReaderAt(r io.Reader) (io.ReaderAt, int64, func(), error) {
+	type readSeekerAt interface {
+		io.ReaderAt
+		io.Seeker
+	}
+	if rs, ok := r.(readSeekerAt); ok {
+		size, err := rs.Seek(0, io.SeekEnd)
+		if err != nil {
+			return nil, 0, nil, fmt.Errorf("runway: couldn't get upload size: %w", err)
+		}
+		return rs, size, func() {}, nil
+	}
+
+	f, err := os.CreateTemp("", "vidai-upload-*")
+	if err != nil {
+		return nil, 0, nil, fmt.Errorf("runway: couldn't create temp file: %w", err)
+	}
+	cleanup := func() {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+	}
+	size, err := io.Copy(f, r)
+	if err != nil {
+		cleanup()
+		return nil, 0, nil, fmt.Errorf("runway: couldn't read upload data: %w", err)
+	}
+	return f, size, cleanup, nil
 }
 
 type deleteRequest struct {
@@ -211,6 +392,9 @@ type deleteResponse struct {
 }
 
 func (c *Client) Delete(ctx context.Context, assetID string) error {
+	if err := c.loadTeamID(ctx); err != nil {
+		return fmt.Errorf("runway: couldn't load team id: %w", err)
+	}
 	path := fmt.Sprintf("assets/%s", assetID)
 	req := &deleteRequest{
 		AsTeamID: c.teamID,
@@ -276,6 +460,7 @@ type gen3Options struct {
 	Flip            bool   `json:"flip,omitempty"`
 	Resolution      string `json:"resolution,omitempty"`
 	InitImage       string `json:"init_image,omitempty"`
+	InitVideo       string `json:"init_video,omitempty"`
 	ImageAsEndFrame bool   `json:"image_as_end_frame"`
 	AssetGroupName  string `json:"assetGroupName"`
 }
@@ -285,20 +470,20 @@ type taskResponse struct {
 }
 
 type taskData struct {
-	ID                          string      `json:"id"`
-	Name                        string      `json:"name"`
-	CreatedAt                   string      `json:"createdAt"`
-	UpdatedAt                   string      `json:"updatedAt"`
-	TaskType                    string      `json:"taskType"`
-	Options                     any         `json:"options"`
-	Status                      string      `json:"status"`
-	Error                       taskError   `json:"error"`
-	ProgressText                string      `json:"progressText"`
-	ProgressRatio               string      `json:"progressRatio"`
-	PlaceInLine                 int         `json:"placeInLine"`
-	EstimatedTimeToStartSeconds float64     `json:"estimatedTimeToStartSeconds"`
-	Artifacts                   []artifact  `json:"artifacts"`
-	SharedAsset                 interface{} `json:"sharedAsset"`
+	ID                          string          `json:"id"`
+	Name                        string          `json:"name"`
+	CreatedAt                   string          `json:"createdAt"`
+	UpdatedAt                   string          `json:"updatedAt"`
+	TaskType                    string          `json:"taskType"`
+	Options                     json.RawMessage `json:"options"`
+	Status                      string          `json:"status"`
+	Error                       taskError       `json:"error"`
+	ProgressText                string          `json:"progressText"`
+	ProgressRatio               string          `json:"progressRatio"`
+	PlaceInLine                 int             `json:"placeInLine"`
+	EstimatedTimeToStartSeconds float64         `json:"estimatedTimeToStartSeconds"`
+	Artifacts                   []artifact      `json:"artifacts"`
+	SharedAsset                 interface{}     `json:"sharedAsset"`
 }
 
 type taskError struct {
@@ -343,6 +528,8 @@ type Generation struct {
 	URL         string   `json:"url"`
 	S3URL       string   `json:"s3Url"`
 	PreviewURLs []string `json:"previewUrls"`
+	TaskID      string   `json:"taskId"`
+	Seed        int      `json:"seed"`
 }
 
 type GenerateRequest struct {
@@ -528,6 +715,7 @@ func (c *Client) Generate(ctx context.Context, cfg *GenerateRequest) (*Generatio
 				Height:          height,
 				Flip:            flip,
 				InitImage:       imageURL,
+				InitVideo:       videoURL,
 				Resolution:      resolution,
 				AssetGroupName:  c.folder,
 				ImageAsEndFrame: cfg.LastFrame,
@@ -558,6 +746,26 @@ func (c *Client) Generate(ctx context.Context, cfg *GenerateRequest) (*Generatio
 		return nil, fmt.Errorf("runway: couldn't create task: %w", err)
 	}
 
+	// Cancel the task if the context is cancelled before it finishes
+	taskID := taskResp.Task.ID
+	defer func() {
+		if ctx.Err() == nil {
+			return
+		}
+		switch taskResp.Task.Status {
+		case "PENDING", "RUNNING", "THROTTLED":
+		default:
+			return
+		}
+		cancelCtx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+		defer cancel()
+		if err := c.CancelTask(cancelCtx, taskID); err != nil {
+			log.Println(err)
+			return
+		}
+		c.log("runway: task %s cancelled", taskID)
+	}()
+
 	// Wait for task to finish
 	for {
 		switch taskResp.Task.Status {
@@ -578,6 +786,8 @@ func (c *Client) Generate(ctx context.Context, cfg *GenerateRequest) (*Generatio
 				URL:         artifact.URL,
 				S3URL:       s3URL,
 				PreviewURLs: artifact.PreviewURLs,
+				TaskID:      taskID,
+				Seed:        seed,
 			}, nil
 		case "PENDING", "RUNNING", "THROTTLED":
 			c.log("runway: task %s: %s", taskResp.Task.ID, taskResp.Task.ProgressRatio)
@@ -599,6 +809,24 @@ func (c *Client) Generate(ctx context.Context, cfg *GenerateRequest) (*Generatio
 	}
 }
 
+type cancelTaskRequest struct {
+	AsTeamID int `json:"asTeamId"`
+}
+
+func (c *Client) CancelTask(ctx context.Context, id string) error {
+	if err := c.loadTeamID(ctx); err != nil {
+		return fmt.Errorf("runway: couldn't load team id: %w", err)
+	}
+	path := fmt.Sprintf("tasks/%s", id)
+	req := &cancelTaskRequest{
+		AsTeamID: c.teamID,
+	}
+	if _, err := c.do(ctx, "DELETE", path, req, nil); err != nil {
+		return fmt.Errorf("runway: couldn't cancel task %s: %w", id, err)
+	}
+	return nil
+}
+
 type assetDeleteRequest struct {
 }
 
@@ -640,6 +868,32 @@ func (c *Client) GetAsset(ctx context.Context, id string) (string, string, []str
 	return s3URL, resp.Asset.URL, resp.Asset.PreviewURLs, nil
 }
 
+// assetExists returns whether the asset is still available.
+func (c *Client) assetExists(ctx context.Context, id string) (bool, error) {
+	path := fmt.Sprintf("assets/%s", id)
+	var resp assetResponse
+	if _, err := c.do(ctx, "GET", path, nil, &resp); err != nil {
+		var errStatus errStatusCode
+		if errors.As(err, &errStatus) && int(errStatus) == http.StatusNotFound {
+			return false, nil
+		}
+		return false, fmt.Errorf("runway: couldn't get asset %s: %w", id, err)
+	}
+	return !resp.Asset.Deleted, nil
+}
+
+// Fetch downloads the content of an absolute URL.
+func (c *Client) Fetch(ctx context.Context, u string) ([]byte, error) {
+	if !strings.HasPrefix(u, "http://") && !strings.HasPrefix(u, "https://") {
+		return nil, fmt.Errorf("runway: invalid url %s", u)
+	}
+	b, err := c.do(ctx, "GET", u, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("runway: couldn't fetch %s: %w", u, err)
+	}
+	return b, nil
+}
+
 func (c *Client) Download(ctx context.Context, u, output string) error {
 	b, err := c.do(ctx, "GET", u, nil, nil)
 	if err != nil {
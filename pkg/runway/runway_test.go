@@ -2,6 +2,8 @@ package runway
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"testing"
 )
 
@@ -85,3 +87,55 @@ func TestUnmarshal(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestToTask(t *testing.T) {
+	js := `{
+	"id": "00000000-0000-0000-0000-000000000000",
+	"createdAt": "2024-01-01T01:01:01.001Z",
+	"updatedAt": "2024-01-01T01:02:01.001Z",
+	"taskType": "gen3a",
+	"options": {"seconds": 10, "exploreMode": false},
+	"status": "SUCCEEDED",
+	"error": null,
+	"artifacts": [{"url": "https://a.url.test"}]
+}`
+	var d taskData
+	if err := json.Unmarshal([]byte(js), &d); err != nil {
+		t.Fatal(err)
+	}
+	task, err := toTask(&d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if task.Seconds != 10 {
+		t.Errorf("expected 10 seconds, got %d", task.Seconds)
+	}
+	if task.Credits != 100 {
+		t.Errorf("expected 100 credits, got %d", task.Credits)
+	}
+	if task.CreatedAt.Minute() != 1 || task.UpdatedAt.Minute() != 2 {
+		t.Errorf("unexpected dates %s %s", task.CreatedAt, task.UpdatedAt)
+	}
+	if len(task.ArtifactURLs) != 1 {
+		t.Errorf("expected 1 artifact url, got %d", len(task.ArtifactURLs))
+	}
+}
+
+func TestIsAuthError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{fmt.Errorf("vidai: couldn't create client: %w", ErrTokenExpired), true},
+		{fmt.Errorf("runway: couldn't create task: %w", errStatusCode(401)), true},
+		{fmt.Errorf("runway: couldn't create task: %w", errStatusCode(403)), true},
+		{fmt.Errorf("runway: couldn't create task: %w", errStatusCode(500)), false},
+		{errors.New("runway: task failed"), false},
+		{nil, false},
+	}
+	for _, tt := range tests {
+		if got := IsAuthError(tt.err); got != tt.want {
+			t.Errorf("IsAuthError(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
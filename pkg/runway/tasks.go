@@ -0,0 +1,173 @@
+package runway
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Task struct {
+	ID                 string    `json:"id"`
+	Name               string    `json:"name"`
+	TaskType           string    `json:"taskType"`
+	Status             string    `json:"status"`
+	CreatedAt          time.Time `json:"createdAt"`
+	UpdatedAt          time.Time `json:"updatedAt"`
+	Seconds            int       `json:"seconds"`
+	ExploreMode        bool      `json:"exploreMode"`
+	Credits            int       `json:"credits"`
+	Reason             string    `json:"reason,omitempty"`
+	Message            string    `json:"message,omitempty"`
+	ModerationCategory string    `json:"moderationCategory,omitempty"`
+	ArtifactURLs       []string  `json:"artifactUrls,omitempty"`
+}
+
+// Moderated returns true if the task was rejected by a safety filter.
+func (t *Task) Moderated() bool {
+	return t.ModerationCategory != "" || isSafetyReason(t.Reason)
+}
+
+type ListTasksRequest struct {
+	Status   string
+	TaskType string
+	Since    time.Time
+	Until    time.Time
+	Offset   int
+	// Limit is the maximum number of tasks to return, 0 means no limit.
+	Limit int
+}
+
+type listTasksResponse struct {
+	Tasks []taskData `json:"tasks"`
+}
+
+const listTasksPageSize = 50
+
+// ListTasks returns the tasks of the account sorted from newest to oldest.
+// Pages are requested until the limit is reached or tasks older than the
+// since date are found.
+func (c *Client) ListTasks(ctx context.Context, req *ListTasksRequest) ([]*Task, error) {
+	if err := c.loadTeamID(ctx); err != nil {
+		return nil, fmt.Errorf("runway: couldn't load team id: %w", err)
+	}
+
+	var tasks []*Task
+	offset := req.Offset
+	for {
+		params := url.Values{}
+		params.Set("offset", strconv.Itoa(offset))
+		params.Set("limit", strconv.Itoa(listTasksPageSize))
+		params.Set("asTeamId", strconv.Itoa(c.teamID))
+		if req.Status != "" {
+			params.Set("status", req.Status)
+		}
+		if req.TaskType != "" {
+			params.Set("taskType", req.TaskType)
+		}
+		path := fmt.Sprintf("tasks?%s", params.Encode())
+		var resp listTasksResponse
+		if _, err := c.do(ctx, "GET", path, nil, &resp); err != nil {
+			return nil, fmt.Errorf("runway: couldn't list tasks: %w", err)
+		}
+
+		var older bool
+		for _, d := range resp.Tasks {
+			t, err := toTask(&d)
+			if err != nil {
+				return nil, err
+			}
+			if !req.Since.IsZero() && t.CreatedAt.Before(req.Since) {
+				older = true
+				continue
+			}
+			if !req.Until.IsZero() && t.CreatedAt.After(req.Until) {
+				continue
+			}
+			// Filter also on the client side in case the server ignores
+			// the query parameters.
+			if req.Status != "" && t.Status != req.Status {
+				continue
+			}
+			if req.TaskType != "" && t.TaskType != req.TaskType {
+				continue
+			}
+			tasks = append(tasks, t)
+			if req.Limit > 0 && len(tasks) >= req.Limit {
+				return tasks, nil
+			}
+		}
+		if older || len(resp.Tasks) < listTasksPageSize {
+			return tasks, nil
+		}
+		offset += len(resp.Tasks)
+	}
+}
+
+type taskOptions struct {
+	Seconds     int  `json:"seconds"`
+	ExploreMode bool `json:"exploreMode"`
+}
+
+func toTask(d *taskData) (*Task, error) {
+	t := &Task{
+		ID:                 d.ID,
+		Name:               d.Name,
+		TaskType:           d.TaskType,
+		Status:             d.Status,
+		Reason:             d.Error.Reason,
+		Message:            d.Error.Message,
+		ModerationCategory: d.Error.ModerationCategory,
+	}
+	var err error
+	if d.CreatedAt != "" {
+		t.CreatedAt, err = time.Parse(time.RFC3339, d.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("runway: couldn't parse created at %q: %w", d.CreatedAt, err)
+		}
+	}
+	if d.UpdatedAt != "" {
+		t.UpdatedAt, err = time.Parse(time.RFC3339, d.UpdatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("runway: couldn't parse updated at %q: %w", d.UpdatedAt, err)
+		}
+	}
+	if len(d.Options) > 0 {
+		var opts taskOptions
+		if err := json.Unmarshal(d.Options, &opts); err == nil {
+			t.Seconds = opts.Seconds
+			t.ExploreMode = opts.ExploreMode
+		}
+	}
+	for _, a := range d.Artifacts {
+		t.ArtifactURLs = append(t.ArtifactURLs, a.URL)
+	}
+	t.Credits = estimateCredits(t)
+	return t, nil
+}
+
+// creditsPerSecond is the estimated cost of each task type in credits per
+// second of generated video.
+var creditsPerSecond = map[string]int{
+	"gen2":        5,
+	"gen3a":       10,
+	"gen3a_turbo": 5,
+}
+
+// estimateCredits returns the credits consumed by a task. Only succeeded tasks
+// that weren't generated in explore mode consume credits.
+func estimateCredits(t *Task) int {
+	if t.Status != "SUCCEEDED" || t.ExploreMode {
+		return 0
+	}
+	return creditsPerSecond[t.TaskType] * t.Seconds
+}
+
+func isSafetyReason(r string) bool {
+	return strings.HasPrefix(r, "SAFETY.") ||
+		strings.Contains(r, ".SAFETY.") ||
+		r == "Text prompt did not pass moderation"
+}
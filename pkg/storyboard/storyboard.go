@@ -0,0 +1,212 @@
+package storyboard
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/igolaizola/vidai/pkg/ffmpeg"
+	"gopkg.in/yaml.v2"
+)
+
+// Storyboard is a list of shots assembled into a single video.
+// Top level values are the defaults of the shots.
+type Storyboard struct {
+	Output             string        `yaml:"output"`
+	Model              string        `yaml:"model"`
+	Seconds            int           `yaml:"seconds"`
+	Transition         string        `yaml:"transition"`
+	TransitionDuration time.Duration `yaml:"transition_duration"`
+	TitleDuration      time.Duration `yaml:"title_duration"`
+	Shots              []Shot        `yaml:"shots"`
+}
+
+// Shot is a single video generated from an image, a video or a text prompt.
+type Shot struct {
+	// ID identifies the shot in logs and cached file names, defaults to
+	// shot-<n>.
+	ID      string `yaml:"id"`
+	Image   string `yaml:"image"`
+	Video   string `yaml:"video"`
+	Text    string `yaml:"text"`
+	Model   string `yaml:"model"`
+	Seconds int    `yaml:"seconds"`
+	// Extend is the number of extensions added to the shot.
+	Extend int `yaml:"extend"`
+	// Prompts of each extension, with the same format as the prompts flag.
+	Prompts string `yaml:"prompts"`
+
+	// Transition from the previous shot, it is ignored in the first shot.
+	Transition         string        `yaml:"transition"`
+	TransitionDuration time.Duration `yaml:"transition_duration"`
+	// Title drawn over the start of the shot (optional).
+	Title         string        `yaml:"title"`
+	TitleDuration time.Duration `yaml:"title_duration"`
+}
+
+// Load reads a storyboard file and fills the shots with the defaults.
+// Relative paths of the shots are resolved from the storyboard directory.
+func Load(file string) (*Storyboard, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("storyboard: couldn't read file: %w", err)
+	}
+	sb, err := Parse(b)
+	if err != nil {
+		return nil, err
+	}
+	dir := filepath.Dir(file)
+	for i := range sb.Shots {
+		sb.Shots[i].Image = resolve(dir, sb.Shots[i].Image)
+		sb.Shots[i].Video = resolve(dir, sb.Shots[i].Video)
+	}
+	if sb.Output != "" && !filepath.IsAbs(sb.Output) {
+		sb.Output = filepath.Join(dir, sb.Output)
+	}
+	return sb, nil
+}
+
+// Parse parses a storyboard and fills the shots with the defaults.
+func Parse(b []byte) (*Storyboard, error) {
+	var sb Storyboard
+	if err := yaml.UnmarshalStrict(b, &sb); err != nil {
+		return nil, fmt.Errorf("storyboard: couldn't parse yaml: %w", err)
+	}
+	if len(sb.Shots) == 0 {
+		return nil, fmt.Errorf("storyboard: no shots")
+	}
+	ids := map[string]bool{}
+	for i := range sb.Shots {
+		s := &sb.Shots[i]
+		if s.ID == "" {
+			s.ID = fmt.Sprintf("shot-%d", i+1)
+		}
+		if s.Model == "" {
+			s.Model = sb.Model
+		}
+		if s.Seconds == 0 {
+			s.Seconds = sb.Seconds
+		}
+		if s.Transition == "" {
+			s.Transition = sb.Transition
+		}
+		if s.TransitionDuration == 0 {
+			s.TransitionDuration = sb.TransitionDuration
+		}
+		if s.TitleDuration == 0 {
+			s.TitleDuration = sb.TitleDuration
+		}
+		if err := s.validate(); err != nil {
+			return nil, fmt.Errorf("storyboard: shot %s: %w", s.ID, err)
+		}
+		if ids[s.ID] {
+			return nil, fmt.Errorf("storyboard: duplicated shot id %s", s.ID)
+		}
+		ids[s.ID] = true
+	}
+	return &sb, nil
+}
+
+func (s *Shot) validate() error {
+	switch {
+	case s.Image == "" && s.Video == "" && s.Text == "":
+		return fmt.Errorf("image, video or text is required")
+	case s.Image != "" && s.Video != "":
+		return fmt.Errorf("image and video can't be used together")
+	case s.Image == "-" || s.Video == "-":
+		return fmt.Errorf("stdin can't be used as source")
+	case s.Extend < 0:
+		return fmt.Errorf("extend can't be negative")
+	case strings.ContainsAny(s.ID, `/\`):
+		return fmt.Errorf("id can't contain path separators")
+	case !ffmpeg.ValidTransition(s.Transition):
+		return fmt.Errorf("unknown transition %q (cut, fade or an ffmpeg xfade transition)", s.Transition)
+	case s.TransitionDuration < 0:
+		return fmt.Errorf("transition duration can't be negative")
+	case s.Transition != "" && s.Transition != ffmpeg.TransitionCut && s.TransitionDuration == 0:
+		return fmt.Errorf("transition %s requires a duration", s.Transition)
+	case s.TitleDuration < 0:
+		return fmt.Errorf("title duration can't be negative")
+	}
+	return nil
+}
+
+// Key returns a hash of everything that changes the generated shot.
+// Transitions and titles are applied when the shots are assembled, so they
+// aren't part of the key. Local sources are hashed by their content.
+func (s *Shot) Key() (string, error) {
+	spec := struct {
+		Image   string
+		Video   string
+		Text    string
+		Model   string
+		Seconds int
+		Extend  int
+		Prompts string
+		Source  string
+	}{
+		Image:   s.Image,
+		Video:   s.Video,
+		Text:    s.Text,
+		Model:   s.Model,
+		Seconds: s.Seconds,
+		Extend:  s.Extend,
+		Prompts: s.Prompts,
+	}
+	for _, src := range []string{s.Image, s.Video} {
+		if !isLocal(src) {
+			continue
+		}
+		sum, err := fileHash(src)
+		if err != nil {
+			return "", err
+		}
+		spec.Source = sum
+	}
+	b, err := json.Marshal(spec)
+	if err != nil {
+		return "", fmt.Errorf("storyboard: couldn't marshal shot: %w", err)
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func fileHash(file string) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", fmt.Errorf("storyboard: couldn't open source: %w", err)
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("storyboard: couldn't read source: %w", err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// isLocal returns true if the source is a local file and not an URL, a
+// runway asset or stdin.
+func isLocal(src string) bool {
+	switch {
+	case src == "", src == "-":
+		return false
+	case strings.HasPrefix(src, "asset:"),
+		strings.HasPrefix(src, "http://"),
+		strings.HasPrefix(src, "https://"):
+		return false
+	}
+	return true
+}
+
+func resolve(dir, src string) string {
+	if !isLocal(src) || filepath.IsAbs(src) {
+		return src
+	}
+	return filepath.Join(dir, src)
+}
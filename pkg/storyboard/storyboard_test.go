@@ -0,0 +1,119 @@
+package storyboard
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const sample = `
+output: film.mp4
+model: gen3-turbo
+seconds: 5
+transition: fade
+transition_duration: 500ms
+shots:
+  - image: car.jpg
+    text: a car driving at night
+    title: "Chapter 1: the road"
+  - id: stop
+    text: the car stops
+    model: gen3
+    seconds: 10
+    extend: 2
+    prompts: "the driver gets out; the driver walks away"
+    transition: cut
+`
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "storyboard.yaml")
+	if err := os.WriteFile(file, []byte(sample), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	sb, err := Load(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sb.Output != filepath.Join(dir, "film.mp4") {
+		t.Errorf("unexpected output %s", sb.Output)
+	}
+	if len(sb.Shots) != 2 {
+		t.Fatalf("expected 2 shots, got %d", len(sb.Shots))
+	}
+	first, second := sb.Shots[0], sb.Shots[1]
+	if first.ID != "shot-1" || first.Image != filepath.Join(dir, "car.jpg") ||
+		first.Model != "gen3-turbo" || first.Seconds != 5 ||
+		first.Transition != "fade" || first.TransitionDuration != 500*time.Millisecond {
+		t.Errorf("unexpected first shot %+v", first)
+	}
+	if second.ID != "stop" || second.Model != "gen3" || second.Seconds != 10 ||
+		second.Extend != 2 || second.Transition != "cut" {
+		t.Errorf("unexpected second shot %+v", second)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := map[string]string{
+		"no shots":            "model: gen3\n",
+		"unknown field":       "shots:\n  - text: a\n    fps: 24\n",
+		"no source":           "shots:\n  - model: gen3\n",
+		"image & video":       "shots:\n  - image: a.jpg\n    video: a.mp4\n",
+		"duplicated id":       "shots:\n  - id: a\n    text: a\n  - id: a\n    text: b\n",
+		"bad transition":      "shots:\n  - text: a\n  - text: b\n    transition: wipe\n    transition_duration: 1s\n",
+		"filter transition":   "shots:\n  - text: a\n  - text: b\n    transition: \"fade:duration=9[x]\"\n    transition_duration: 1s\n",
+		"no duration":         "shots:\n  - text: a\n  - text: b\n    transition: dissolve\n",
+		"default no duration": "transition: fade\nshots:\n  - text: a\n  - text: b\n",
+		"negative duration":   "shots:\n  - text: a\n  - text: b\n    transition: fade\n    transition_duration: -1s\n",
+	}
+	for name, in := range tests {
+		if _, err := Parse([]byte(in)); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+
+	// Cuts don't need a duration
+	if _, err := Parse([]byte("shots:\n  - text: a\n  - text: b\n    transition: cut\n")); err != nil {
+		t.Errorf("cut: %v", err)
+	}
+}
+
+func TestKey(t *testing.T) {
+	dir := t.TempDir()
+	img := filepath.Join(dir, "car.jpg")
+	if err := os.WriteFile(img, []byte("a"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	shot := Shot{Image: img, Text: "a car", Model: "gen3", Seconds: 5}
+	key := func(s Shot) string {
+		t.Helper()
+		k, err := s.Key()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return k
+	}
+	base := key(shot)
+
+	// Titles and transitions don't change the generated shot
+	titled := shot
+	titled.Title = "Chapter 1"
+	titled.Transition = "wipeleft"
+	if key(titled) != base {
+		t.Error("expected same key for title and transition changes")
+	}
+
+	changed := shot
+	changed.Seconds = 10
+	if key(changed) == base {
+		t.Error("expected different key for seconds change")
+	}
+
+	if err := os.WriteFile(img, []byte("b"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if key(shot) == base {
+		t.Error("expected different key for image content change")
+	}
+}
@@ -0,0 +1,52 @@
+package workspace
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// Workspace is a directory to store the temporary files of a single run so
+// that concurrent runs don't overwrite each other's files.
+type Workspace struct {
+	dir  string
+	keep bool
+}
+
+// New creates a workspace inside parent, or inside the system temp dir if
+// parent is empty. If keep is set, the workspace isn't removed when it is
+// closed.
+func New(parent, name string, keep bool) (*Workspace, error) {
+	if parent != "" {
+		if err := os.MkdirAll(parent, 0o755); err != nil {
+			return nil, fmt.Errorf("workspace: couldn't create parent dir: %w", err)
+		}
+	}
+	dir, err := os.MkdirTemp(parent, fmt.Sprintf("vidai-%s-*", name))
+	if err != nil {
+		return nil, fmt.Errorf("workspace: couldn't create dir: %w", err)
+	}
+	return &Workspace{dir: dir, keep: keep}, nil
+}
+
+// Dir returns the workspace directory.
+func (w *Workspace) Dir() string {
+	return w.dir
+}
+
+// Path returns the path of a file inside the workspace.
+func (w *Workspace) Path(name string) string {
+	return filepath.Join(w.dir, name)
+}
+
+// Close removes the workspace and all its files unless it must be kept.
+func (w *Workspace) Close() {
+	if w.keep {
+		log.Printf("workspace: temp files kept in %s\n", w.dir)
+		return
+	}
+	if err := os.RemoveAll(w.dir); err != nil {
+		log.Println(fmt.Errorf("workspace: couldn't remove %s: %w", w.dir, err))
+	}
+}
@@ -0,0 +1,38 @@
+package workspace
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWorkspace(t *testing.T) {
+	parent := filepath.Join(t.TempDir(), "work")
+
+	a, err := New(parent, "test", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := New(parent, "test", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Dir() == b.Dir() {
+		t.Fatalf("expected different dirs, got %s", a.Dir())
+	}
+	if filepath.Dir(a.Dir()) != parent {
+		t.Errorf("expected workspace inside %s, got %s", parent, a.Dir())
+	}
+	for _, w := range []*Workspace{a, b} {
+		if err := os.WriteFile(w.Path("file.mp4"), []byte("data"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		w.Close()
+	}
+	if _, err := os.Stat(a.Dir()); !os.IsNotExist(err) {
+		t.Errorf("expected %s to be removed", a.Dir())
+	}
+	if _, err := os.Stat(b.Path("file.mp4")); err != nil {
+		t.Errorf("expected %s to be kept: %v", b.Path("file.mp4"), err)
+	}
+}